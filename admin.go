@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+func (h *Handler) integrityHandler(w http.ResponseWriter, r *http.Request) {
+	// call DB layer
+	report, err := checkIntegrity(h.dbFor(r))
+	if err != nil {
+		internalError(w, "Error while checking integrity", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, report)
+}
+
+func (h *Handler) diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	// call DB layer
+	report, err := runDiagnostics(h.dbFor(r))
+	if err != nil {
+		internalError(w, "Error while running diagnostics", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, report)
+}
+
+func (h *Handler) recomputeSalariesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var job SalaryRecompute
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if _, ok := salaryOperations[job.Op]; !ok {
+		http.Error(w, "op must be multiply or add", http.StatusBadRequest)
+		return
+	}
+	if job.Op == "multiply" && job.Factor <= 0 {
+		http.Error(w, "factor must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	if job.Op == "add" && job.Amount == 0 {
+		http.Error(w, "amount cannot be 0", http.StatusBadRequest)
+		return
+	}
+	preview := r.URL.Query().Get("preview") == "true"
+
+	// call DB layer
+	matched, err := recomputeSalaries(h.dbFor(r), job, preview, h.cfg.salaryMin(), h.cfg.salaryMax())
+	if err != nil {
+		if errors.Is(err, ErrSalaryOutOfRange) {
+			http.Error(w, "A recomputed salary would be outside the allowed range, nothing was changed",
+				http.StatusBadRequest)
+			return
+		}
+		internalError(w, "Error while recomputing salaries", err)
+		return
+	}
+	if !preview {
+		h.cache.clear()
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, map[string]interface{}{"matched": matched, "applied": !preview})
+}
+
+func (h *Handler) retryWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if h.webhooks == nil {
+		http.Error(w, "Webhooks are not configured, set WEBHOOK_URL", http.StatusConflict)
+		return
+	}
+
+	// call DB layer
+	result, err := h.webhooks.retryDeadLetters()
+	if err != nil {
+		internalError(w, "Error while retrying webhook deliveries", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrityHandler_PASS_Healthy(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.integrityHandler)).ServeHTTP(rr, req)
+
+	var report IntegrityReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, report.OK)
+	assert.Equal(t, []string{"ok"}, report.IntegrityCheck)
+	assert.Empty(t, report.ForeignKeyViolations)
+}
+
+func TestIntegrityHandler_PASS_Reports_Orphaned_Tags(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	// Seed a join row pointing at a missing employee, as a bulk import
+	// with foreign keys off would
+	addEmployeeTag(db, 2, "remote")
+	db.Exec("PRAGMA foreign_keys = OFF")
+	db.Exec("INSERT INTO employee_tags (employee_id, tag_id) VALUES (22, 1)")
+	db.Exec("PRAGMA foreign_keys = ON")
+
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.integrityHandler)).ServeHTTP(rr, req)
+
+	var report IntegrityReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, report.OK)
+	assert.Equal(t, 1, len(report.ForeignKeyViolations))
+	assert.Equal(t, "employee_tags", report.ForeignKeyViolations[0].Table)
+	assert.Equal(t, "employees", report.ForeignKeyViolations[0].Parent)
+}
+
+func TestIntegrityHandler_FAIL_Not_Admin(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.integrityHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestIntegrityHandler_PASS_Reports_Dangling_Manager(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	// Seed a manager reference to an employee that does not exist
+	db.Exec("PRAGMA foreign_keys = OFF")
+	db.Exec("UPDATE employees SET manager_id = 99 WHERE id = 3")
+	db.Exec("PRAGMA foreign_keys = ON")
+
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.integrityHandler)).ServeHTTP(rr, req)
+
+	var report IntegrityReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+
+	assert.False(t, report.OK)
+	assert.Equal(t, 1, len(report.ForeignKeyViolations))
+	assert.Equal(t, "employees", report.ForeignKeyViolations[0].Table)
+	assert.Equal(t, int64(3), report.ForeignKeyViolations[0].RowID)
+}
+
+func TestDiagnosticsHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET name = '' WHERE id = 4")
+
+	req := httptest.NewRequest("GET", "/admin/diagnostics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.diagnosticsHandler)).ServeHTTP(rr, req)
+
+	var report map[string]int64
+	json.Unmarshal(rr.Body.Bytes(), &report)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]int64{"rowCount": 4, "maxId": 44, "nullNameCount": 1}, report)
+}
+
+func TestDiagnosticsHandler_FAIL_Not_Admin(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/admin/diagnostics", nil)
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.diagnosticsHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// Post the recomputation job as an admin
+func postRecomputeSalaries(handler Handler, target string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.requireAdmin(http.HandlerFunc(handler.recomputeSalariesHandler)).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRecomputeSalariesHandler_PASS_Preview(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Eng' WHERE id IN (2, 3)")
+
+	rr := postRecomputeSalaries(handler, "/admin/recomputeSalaries?preview=true",
+		`{"op":"multiply","factor":1.03,"filter":{"department":"Eng"}}`)
+
+	// Check the matches are counted but nothing changes
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"matched":2,"applied":false}`, rr.Body.String())
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, Cents(60000_00), employee.Salary)
+}
+
+func TestRecomputeSalariesHandler_PASS_Apply(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Eng' WHERE id IN (2, 3)")
+
+	rr := postRecomputeSalaries(handler, "/admin/recomputeSalaries",
+		`{"op":"multiply","factor":1.03,"filter":{"department":"Eng"}}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"matched":2,"applied":true}`, rr.Body.String())
+
+	// Check only the department got the raise
+	for id, salary := range map[int]Cents{2: 61800_00, 3: 2060_00, 4: 1000_00} {
+		employee, _ := getEmployeeById(db, id)
+		assert.Equal(t, salary, employee.Salary)
+	}
+
+	// Adding applies to everyone without a filter
+	rr = postRecomputeSalaries(handler, "/admin/recomputeSalaries", `{"op":"add","amount":100.50}`)
+	assert.JSONEq(t, `{"matched":4,"applied":true}`, rr.Body.String())
+	employee, _ := getEmployeeById(db, 4)
+	assert.Equal(t, Cents(1100_50), employee.Salary)
+	assert.Equal(t, 2, employee.Version)
+}
+
+func TestRecomputeSalariesHandler_FAIL_Out_Of_Range(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	// Taking 1500.00 off would make some salaries negative
+	rr := postRecomputeSalaries(handler, "/admin/recomputeSalaries", `{"op":"add","amount":-1500}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Check the whole change was rolled back
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, Cents(60000_00), employee.Salary)
+}
+
+func TestRecomputeSalariesHandler_FAIL_Unknown_Op(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret"}}
+	defer handler.db.Close()
+
+	rr := postRecomputeSalaries(handler, "/admin/recomputeSalaries", `{"op":"DROP TABLE employees"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "op must be multiply or add\n", rr.Body.String())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Read-through cache of employees keyed by ID. A nil cache is valid and
+// always loads from the source.
+type employeeCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[int]cacheEntry
+	now     func() time.Time
+	// Bumped by invalidate and clear, so a load that started before a
+	// write does not cache the row it read
+	generations map[int]uint64
+	epoch       uint64
+}
+
+type cacheEntry struct {
+	employee Employee
+	expires  time.Time
+}
+
+func newEmployeeCache(ttl time.Duration) *employeeCache {
+	return &employeeCache{ttl: ttl, entries: make(map[int]cacheEntry), now: time.Now,
+		generations: make(map[int]uint64)}
+}
+
+// Return the cached employee, calling load and caching the result on a miss
+func (c *employeeCache) get(id int, load func(id int) (Employee, error)) (Employee, error) {
+	if c == nil {
+		return load(id)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	generation, epoch := c.generations[id], c.epoch
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.employee, nil
+	}
+
+	employee, err := load(id)
+	if err != nil {
+		return Employee{}, err
+	}
+	c.mu.Lock()
+	// Skip caching when the employee was written while loading, the row
+	// read may be the one the write replaced
+	if c.generations[id] == generation && c.epoch == epoch {
+		c.entries[id] = cacheEntry{employee: employee, expires: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return employee, nil
+}
+
+// Drop every entry, for writes that touch many employees at once
+func (c *employeeCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.entries = make(map[int]cacheEntry)
+	c.generations = make(map[int]uint64)
+	c.epoch++
+	c.mu.Unlock()
+}
+
+// Drop the entry so the next read goes to the source
+func (c *employeeCache) invalidate(id int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.generations[id]++
+	c.mu.Unlock()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Fake source counting how often it is read
+type countingRepo struct {
+	calls     int
+	employees map[int]Employee
+}
+
+func (f *countingRepo) load(id int) (Employee, error) {
+	f.calls++
+	return f.employees[id], nil
+}
+
+func TestEmployeeCache_PASS_Hit_Within_TTL(t *testing.T) {
+	repo := &countingRepo{employees: map[int]Employee{2: {ID: 2, Name: "Alice"}}}
+	cache := newEmployeeCache(time.Minute)
+
+	first, _ := cache.get(2, repo.load)
+	second, _ := cache.get(2, repo.load)
+
+	// Check the second read is served from the cache
+	assert.Equal(t, 1, repo.calls)
+	assert.Equal(t, first, second)
+}
+
+func TestEmployeeCache_PASS_Expires_After_TTL(t *testing.T) {
+	repo := &countingRepo{employees: map[int]Employee{2: {ID: 2, Name: "Alice"}}}
+	cache := newEmployeeCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.get(2, repo.load)
+	now = now.Add(2 * time.Minute)
+	cache.get(2, repo.load)
+
+	// Check the expired entry is reloaded
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestEmployeeCache_PASS_Invalidate(t *testing.T) {
+	repo := &countingRepo{employees: map[int]Employee{2: {ID: 2, Name: "Alice"}}}
+	cache := newEmployeeCache(time.Minute)
+
+	cache.get(2, repo.load)
+	cache.invalidate(2)
+	cache.get(2, repo.load)
+
+	// Check the invalidated entry is reloaded
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestEmployeeCache_PASS_Invalidate_During_Load(t *testing.T) {
+	for _, write := range []func(*employeeCache){(*employeeCache).clear, func(c *employeeCache) { c.invalidate(2) }} {
+		cache := newEmployeeCache(time.Minute)
+		repo := &countingRepo{employees: map[int]Employee{2: {ID: 2, Name: "Alice"}}}
+
+		// The employee is updated after the load read it but before it returns
+		cache.get(2, func(id int) (Employee, error) {
+			employee, err := repo.load(id)
+			repo.employees[2] = Employee{ID: 2, Name: "Alice Smith"}
+			write(cache)
+			return employee, err
+		})
+		employee, _ := cache.get(2, repo.load)
+
+		// Check the row read before the write was not cached
+		assert.Equal(t, 2, repo.calls)
+		assert.Equal(t, "Alice Smith", employee.Name)
+	}
+}
+
+func TestGetEmployeeHandler_PASS_Cache_Invalidated_On_Update(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cache: newEmployeeCache(time.Minute)}
+	defer handler.db.Close()
+
+	getName := func() string {
+		req := httptest.NewRequest("GET", "/employees/{id}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "2")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.getEmployeeByIdHandler(rr, req)
+		var employee Employee
+		json.Unmarshal(rr.Body.Bytes(), &employee)
+		return employee.Name
+	}
+
+	// Warm the cache
+	assert.Equal(t, "Alice", getName())
+
+	// Update the employee
+	newEmployee := Employee{ID: 2, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000_00}
+	reqBody, _ := json.Marshal(newEmployee)
+	req := httptest.NewRequest("PUT", "/updateEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.updateEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Check the next read sees the update
+	assert.Equal(t, "Alice Smith", getName())
+}
+
+func TestGetEmployeeHandler_PASS_Cache_Skips_Database(t *testing.T) {
+	db := setupDatabaseWithDriver("sqlite3_counting")
+	handler := Handler{db: db, cache: newEmployeeCache(time.Minute)}
+	defer handler.db.Close()
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/employees/{id}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "2")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.getEmployeeByIdHandler(rr, req)
+		return rr
+	}
+
+	// Check only the first read reaches the database
+	assert.NotZero(t, countQueries(func() { get() }))
+	var rr *httptest.ResponseRecorder
+	assert.Zero(t, countQueries(func() { rr = get() }))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"name":"Alice"`)
+
+	// Check a write sends the next read back to the database
+	handler.cache.invalidate(2)
+	assert.NotZero(t, countQueries(func() { get() }))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config Struct:
+// Settings read from environment variables at startup. The zero value is
+// usable and leaves every optional feature disabled.
+type Config struct {
+	//URL receiving a POST for every employee change, empty to disable webhooks.
+	WebhookURL string
+	//How many times a failed webhook delivery is retried.
+	WebhookRetries int
+	//Delay before the first webhook retry, doubled after each attempt.
+	WebhookBackoff time.Duration
+	//How long employees read by ID stay cached, 0 disables the cache.
+	CacheTTL time.Duration
+	//Responses smaller than this many bytes are not gzipped.
+	GzipMinBytes int
+	//Bearer token identifying admin callers, empty means nobody is admin.
+	AdminToken string
+	//Hide salaries from callers that are not admin.
+	RedactSalary bool
+	//Compute the list total with COUNT(*) OVER() instead of a separate COUNT query.
+	ListTotalWindow bool
+	//Lowest accepted salary, inclusive.
+	SalaryMin Cents
+	//Highest accepted salary, inclusive. 0 means the default of 10,000,000.
+	SalaryMax Cents
+	//Organization name written in exported vCards.
+	OrgName string
+	//Default JSON key naming, camel_case or snake_case.
+	JSONNaming string
+	//Expose the test-only endpoints such as POST /test/reset. Never set in production.
+	TestMode bool
+	//Identical creates received within this window are only inserted once, 0 disables.
+	CreateDedupeWindow time.Duration
+	//Wrap resource and list responses in {"data":...,"meta":...}.
+	Envelope bool
+	//Reject a size or limit below 1 with a 400 instead of using the default size.
+	StrictPagination bool
+	//Longest accepted query string in bytes, 0 for no limit.
+	MaxQueryBytes int
+	//How creates without an ID get one, sequence or uuid. When empty the client must send the ID.
+	IDStrategy string
+	//Longest a request may run before it gets a 503, 0 for no limit. Streaming responses have none.
+	RequestTimeout time.Duration
+	//Timeouts of specific routes by pattern, overriding RequestTimeout.
+	RouteTimeouts map[string]time.Duration
+	//Certificate and key files, HTTPS is served when both are set.
+	TLSCert string
+	TLSKey  string
+	//Oldest TLS version accepted, 1.2 (the default) or 1.3.
+	TLSMinVersion string
+	//Accepted positions, any position is accepted when empty.
+	PositionsAllowlist []string
+	//Upper bounds of the salary bands counted by GET /employees/bands, ascending.
+	SalaryBands []Cents
+	//Feature flags of the newer endpoints to enable (bulk, export, stats), none when empty.
+	Features []string
+	//Zone timestamps are written in, UTC when nil.
+	Timezone *time.Location
+	//Most employees a bulk request (import, validate or bulkUpdate) may carry, 0 for no limit.
+	MaxBatchItems int
+	//Most results a list page may hold whatever its size, 0 for no limit. Streams are not capped.
+	AbsoluteMaxResults int
+	//Reject search, name, department and tag values containing SQL meta-characters with a 400. Names may hold an apostrophe.
+	StrictQueryParams bool
+	//Standard deviations from the mean beyond which GET /employees/outliers lists a salary, 0 means the default of 2.
+	OutlierDeviations float64
+	//Name reported by GET /, "Employees API" when empty.
+	APIName string
+	//Count the DB queries of each request in an X-DB-Queries header.
+	DebugQueries bool
+}
+
+// Highest accepted salary when none is configured
+const defaultSalaryMax Cents = 10_000_000_00
+
+func (c Config) salaryMin() Cents {
+	return c.SalaryMin
+}
+
+func (c Config) salaryMax() Cents {
+	if c.SalaryMax == 0 {
+		return defaultSalaryMax
+	}
+	return c.SalaryMax
+}
+
+func (c Config) timezone() *time.Location {
+	if c.Timezone == nil {
+		return time.UTC
+	}
+	return c.Timezone
+}
+
+// Standard deviations making a salary an outlier when none are configured
+const defaultOutlierDeviations = 2.0
+
+func (c Config) outlierDeviations() float64 {
+	if c.OutlierDeviations <= 0 {
+		return defaultOutlierDeviations
+	}
+	return c.OutlierDeviations
+}
+
+// Salary band bounds used when none are configured: 0-30k, 30k-60k and 60k+
+var defaultSalaryBands = []Cents{30_000_00, 60_000_00}
+
+func (c Config) salaryBands() []Cents {
+	if len(c.SalaryBands) == 0 {
+		return defaultSalaryBands
+	}
+	return c.SalaryBands
+}
+
+// Read the configuration from the environment
+func loadConfig() Config {
+	return Config{
+		WebhookURL:         os.Getenv("WEBHOOK_URL"),
+		WebhookRetries:     envInt("WEBHOOK_RETRIES", 3),
+		WebhookBackoff:     envDuration("WEBHOOK_BACKOFF", time.Second),
+		CacheTTL:           envDuration("CACHE_TTL", 0),
+		GzipMinBytes:       envInt("GZIP_MIN_BYTES", 1024),
+		AdminToken:         os.Getenv("ADMIN_TOKEN"),
+		RedactSalary:       envBool("REDACT_SALARY", false),
+		ListTotalWindow:    os.Getenv("LIST_TOTAL_STRATEGY") == "window",
+		SalaryMin:          envCents("SALARY_MIN", 0),
+		SalaryMax:          envCents("SALARY_MAX", defaultSalaryMax),
+		OrgName:            os.Getenv("ORG_NAME"),
+		JSONNaming:         os.Getenv("JSON_NAMING"),
+		TestMode:           envBool("TEST_MODE", false),
+		CreateDedupeWindow: envDuration("CREATE_DEDUPE_WINDOW", 0),
+		Envelope:           envBool("ENVELOPE", false),
+		StrictPagination:   envBool("STRICT_PAGINATION", false),
+		MaxQueryBytes:      envInt("MAX_QUERY_BYTES", 2048),
+		IDStrategy:         os.Getenv("ID_STRATEGY"),
+		RequestTimeout:     envDuration("REQUEST_TIMEOUT", 0),
+		RouteTimeouts:      envDurationMap("ROUTE_TIMEOUTS"),
+		TLSCert:            os.Getenv("TLS_CERT"),
+		TLSKey:             os.Getenv("TLS_KEY"),
+		TLSMinVersion:      os.Getenv("TLS_MIN_VERSION"),
+		PositionsAllowlist: envList("POSITIONS_ALLOWLIST"),
+		SalaryBands:        envCentsList("SALARY_BANDS", defaultSalaryBands),
+		Features:           envList("FEATURES"),
+		Timezone:           envLocation("TIMEZONE"),
+		MaxBatchItems:      envInt("MAX_BATCH_ITEMS", 1000),
+		AbsoluteMaxResults: envInt("ABSOLUTE_MAX_RESULTS", 1000),
+		StrictQueryParams:  envBool("STRICT_QUERY_PARAMS", false),
+		DebugQueries:       envBool("DEBUG_QUERIES", false),
+		APIName:            os.Getenv("API_NAME"),
+		OutlierDeviations:  envFloat("OUTLIER_DEVIATIONS", defaultOutlierDeviations),
+	}
+}
+
+// Read an integer variable, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Read a decimal variable such as "2.5", falling back to def when unset or invalid
+func envFloat(key string, def float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Read a boolean variable such as "true" or "1", falling back to def when unset or invalid
+func envBool(key string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Read a money amount such as "1500.50", falling back to def when unset or invalid
+func envCents(key string, def Cents) Cents {
+	value, err := decimalToCents(os.Getenv(key))
+	if err != nil || os.Getenv(key) == "" {
+		return def
+	}
+	return Cents(value)
+}
+
+// Read a duration variable such as "500ms", falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Read a timezone name such as "Europe/Paris", nil when unset or unknown
+func envLocation(key string) *time.Location {
+	location, err := time.LoadLocation(os.Getenv(key))
+	if err != nil || os.Getenv(key) == "" {
+		return nil
+	}
+	return location
+}
+
+// Read a comma separated list, dropping blank entries
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Read an ascending list of money amounts such as "30000,60000", falling back
+// to def when unset, invalid or out of order
+func envCentsList(key string, def []Cents) []Cents {
+	var values []Cents
+	for _, value := range envList(key) {
+		cents, err := decimalToCents(value)
+		if err != nil || (len(values) > 0 && Cents(cents) <= values[len(values)-1]) {
+			return def
+		}
+		values = append(values, Cents(cents))
+	}
+	if len(values) == 0 {
+		return def
+	}
+	return values
+}
+
+// Read a list of durations such as "/getEmployees=30s,/employees/{id}=2s",
+// skipping invalid entries
+func envDurationMap(key string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			continue
+		}
+		values[name] = duration
+	}
+	return values
+}
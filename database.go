@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 // CREATE TABLE IF NOT EXISTS employees (
@@ -11,7 +18,14 @@ import (
 //	ID INTEGER PRIMARY KEY,
 //	Name TEXT,
 //	Position TEXT,
-//	Salary REAL
+//	salary_cents INTEGER,
+//	Department TEXT,
+//	hire_date TEXT,
+//	manager_id INTEGER REFERENCES employees(ID),
+//	version INTEGER NOT NULL DEFAULT 1,
+//	created_at TEXT,
+//	updated_at TEXT,
+//	uuid TEXT UNIQUE
 //
 // );
 // Employee Struct:
@@ -22,67 +36,1396 @@ type Employee struct {
 	Name string `json:"name"`
 	//Position/title of the employee.
 	Position string `json:"position"`
-	//Salary of the employee.
-	Salary float64 `json:"salary"`
+	//Salary of the employee, a decimal amount in JSON.
+	Salary Cents `json:"salary"`
+	//Department the employee belongs to, optional.
+	Department string `json:"department"`
+	//Date the employee was hired as YYYY-MM-DD, optional. An RFC 3339 time is accepted and cut to its date.
+	HireDate Date `json:"hireDate"`
+	//ID of the employee's manager, null when they have none.
+	ManagerID *int `json:"managerId"`
+	//Incremented on every update, used for optimistic locking.
+	Version int `json:"version"`
+	//When the employee was created, set by the server.
+	CreatedAt Timestamp `json:"createdAt"`
+	//When the employee was last changed, set by the server.
+	UpdatedAt Timestamp `json:"updatedAt"`
+	//Public identifier when IDs are UUIDs, otherwise empty.
+	UUID string `json:"uuid,omitempty"`
+}
+
+// Returned when no employee has the requested ID
+var ErrEmployeeNotFound = errors.New("employee not found")
+
+// Whether the error is a violated foreign key, such as a manager_id naming
+// no employee or a delete leaving other employees without their manager
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// Returned when deleting an employee that still manages other employees
+var ErrManagerHasReports = errors.New("employee is the manager of other employees")
+
+// Returned when an update expected a version that is no longer current
+var ErrVersionConflict = errors.New("employee was modified concurrently, version conflict")
+
+// Columns selected for an employee, in the order scanEmployee reads them
+const employeeColumns = "id, name, position, salary_cents, COALESCE(department, ''), COALESCE(hire_date, ''), manager_id, version, created_at, updated_at, COALESCE(uuid, '')"
+
+// Implemented by both *sql.DB and *sql.Tx, so reads and writes can run
+// inside or outside a transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Implemented by *sql.DB and requestDB, for the DB layer functions that
+// start their own transactions
+type database interface {
+	querier
+	Begin() (*sql.Tx, error)
+}
+
+// Implemented by *sql.Tx and the transactions of a requestDB
+type transaction interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// Begin a transaction on db, bound to the same context as db
+func begin(db database) (transaction, error) {
+	if rdb, ok := db.(*requestDB); ok {
+		return rdb.begin()
+	}
+	return db.Begin()
+}
+
+// The database as a handler sees it: every statement, including those of its
+// transactions, runs with the request context, so it stops at the request's
+// deadline or once the client is gone, and is counted in debug mode
+type requestDB struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (r *requestDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	countQuery(r.ctx)
+	return r.db.ExecContext(r.ctx, query, args...)
+}
+
+func (r *requestDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	countQuery(r.ctx)
+	return r.db.QueryContext(r.ctx, query, args...)
+}
+
+func (r *requestDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	countQuery(r.ctx)
+	return r.db.QueryRowContext(r.ctx, query, args...)
+}
+
+func (r *requestDB) Begin() (*sql.Tx, error) {
+	return r.db.BeginTx(r.ctx, nil)
+}
+
+func (r *requestDB) begin() (transaction, error) {
+	tx, err := r.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &requestTx{tx: tx, ctx: r.ctx}, nil
+}
+
+type requestTx struct {
+	tx  *sql.Tx
+	ctx context.Context
+}
+
+func (r *requestTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	countQuery(r.ctx)
+	return r.tx.ExecContext(r.ctx, query, args...)
+}
+
+func (r *requestTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	countQuery(r.ctx)
+	return r.tx.QueryContext(r.ctx, query, args...)
+}
+
+func (r *requestTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	countQuery(r.ctx)
+	return r.tx.QueryRowContext(r.ctx, query, args...)
+}
+
+func (r *requestTx) Commit() error {
+	return r.tx.Commit()
+}
+
+func (r *requestTx) Rollback() error {
+	return r.tx.Rollback()
+}
+
+// Implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan a row selected with employeeColumns
+func scanEmployee(row rowScanner) (Employee, error) {
+	var employee Employee
+	var managerID sql.NullInt64
+	err := row.Scan(&employee.ID, &employee.Name, &employee.Position, &employee.Salary, &employee.Department,
+		&employee.HireDate, &managerID, &employee.Version, &employee.CreatedAt, &employee.UpdatedAt,
+		&employee.UUID)
+	if managerID.Valid {
+		id := int(managerID.Int64)
+		employee.ManagerID = &id
+	}
+	return employee, err
+}
+
+// Optional filters applied when listing employees
+type EmployeeFilter struct {
+	//Only return employees carrying this tag.
+	Tag string
+	//Only return employees with an ID of at least IDFrom, 0 for no lower bound.
+	IDFrom int
+	//Only return employees with an ID of at most IDTo, 0 for no upper bound.
+	IDTo int
+	//Only return employees whose name contains this text, ignoring case.
+	Search string
+	//Only return employees of this department.
+	Department string
+	//Only return employees holding exactly this position.
+	Position string
+	//Only return employees earning at least this much, 0 for no lower bound.
+	MinSalary Cents
+}
+
+// Build the WHERE clause and its arguments for the filter
+func (f EmployeeFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Tag != "" {
+		conditions = append(conditions, `id IN (SELECT et.employee_id FROM employee_tags et
+			JOIN tags t ON t.id = et.tag_id WHERE t.name = ?)`)
+		args = append(args, f.Tag)
+	}
+	if f.Search != "" {
+		conditions = append(conditions, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+	}
+	if f.Department != "" {
+		conditions = append(conditions, "department = ?")
+		args = append(args, f.Department)
+	}
+	if f.Position != "" {
+		conditions = append(conditions, "position = ?")
+		args = append(args, f.Position)
+	}
+	if f.MinSalary != 0 {
+		conditions = append(conditions, "salary_cents >= ?")
+		args = append(args, f.MinSalary)
+	}
+	switch {
+	case f.IDFrom != 0 && f.IDTo != 0:
+		conditions = append(conditions, "id BETWEEN ? AND ?")
+		args = append(args, f.IDFrom, f.IDTo)
+	case f.IDFrom != 0:
+		conditions = append(conditions, "id >= ?")
+		args = append(args, f.IDFrom)
+	case f.IDTo != 0:
+		conditions = append(conditions, "id <= ?")
+		args = append(args, f.IDTo)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Escapes the LIKE wildcards so searches match them literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Sort tokens accepted from clients, mapped to the column literal used in
+// ORDER BY. Client input is only ever used as a key into this map so it never
+// reaches the SQL text.
+var sortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"position":   "position",
+	"salary":     "salary_cents",
+	"department": "department",
+	"hireDate":   "hire_date",
+	"createdAt":  "created_at",
+}
+
+// Order in which employees are listed, the zero value sorts by ID ascending
+type EmployeeSort struct {
+	//Token from sortColumns, empty sorts by ID.
+	By string
+	//Sort from highest to lowest.
+	Descending bool
+}
+
+// Build the ORDER BY clause, with the ID as tie breaker so pages are stable
+func (s EmployeeSort) orderBy() string {
+	column, ok := sortColumns[s.By]
+	if !ok {
+		column = "id"
+	}
+	direction := " asc"
+	if s.Descending {
+		direction = " desc"
+	}
+	if column == "id" {
+		return " ORDER BY id" + direction
+	}
+	return " ORDER BY " + column + direction + ", id asc"
+}
+
+// Create the tables if they do not exist yet
+func initSchema(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS employees (
+			ID INTEGER PRIMARY KEY,
+			Name TEXT,
+			Position TEXT,
+			salary_cents INTEGER,
+			Department TEXT,
+			hire_date TEXT,
+			manager_id INTEGER REFERENCES employees(ID),
+			version INTEGER NOT NULL DEFAULT 1,
+			created_at TEXT,
+			updated_at TEXT,
+			uuid TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			ID INTEGER PRIMARY KEY,
+			Name TEXT NOT NULL UNIQUE
+		)`,
+		`CREATE TABLE IF NOT EXISTS employee_tags (
+			employee_id INTEGER NOT NULL REFERENCES employees(ID) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(ID) ON DELETE CASCADE,
+			PRIMARY KEY (employee_id, tag_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS dead_letter (
+			ID INTEGER PRIMARY KEY,
+			event TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			last_error TEXT,
+			failed_at TEXT
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the first release
+	if err := addColumnIfMissing(db, "employees", "Department", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "hire_date", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "manager_id", "INTEGER REFERENCES employees(ID)"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "created_at", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "updated_at", "TEXT"); err != nil {
+		return err
+	}
+	// SQLite cannot add a UNIQUE column, so uniqueness comes from an index
+	if err := addColumnIfMissing(db, "employees", "uuid", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS employees_uuid ON employees(uuid)"); err != nil {
+		return err
+	}
+	return migrateSalaryToCents(db)
+}
+
+// Move salaries stored as REAL by older versions to salary_cents
+func migrateSalaryToCents(db *sql.DB) error {
+	legacy, err := hasColumn(db, "employees", "Salary")
+	if err != nil || !legacy {
+		return err
+	}
+	if err := addColumnIfMissing(db, "employees", "salary_cents", "INTEGER"); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE employees SET salary_cents = CAST(ROUND(Salary * 100) AS INTEGER)
+		WHERE salary_cents IS NULL AND Salary IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec("ALTER TABLE employees DROP COLUMN Salary"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Add the column to a table created by an older version
+func addColumnIfMissing(db *sql.DB, table string, column string, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// Whether the table has the column, compared case-insensitively like SQLite does
+func hasColumn(db *sql.DB, table string, column string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 // Insert the employee
-func createEmployee(db *sql.DB, emp Employee) error {
-	_, err := db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (?, ?, ?, ?)",
-		emp.ID, emp.Name, emp.Position, emp.Salary)
+func createEmployee(db querier, emp Employee) error {
+	_, err := db.Exec(`INSERT INTO employees (id, name, position, salary_cents, department, hire_date, manager_id,
+		uuid, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, `+sqlNow+`, `+sqlNow+`)`,
+		emp.ID, emp.Name, emp.Position, emp.Salary, emp.Department, emp.HireDate, emp.ManagerID, emp.uuidValue())
 	return err
 }
 
-// Update the employee
-func updateEmployee(db *sql.DB, emp Employee) error {
+// Insert the employee, letting the DB assign the ID
+func createEmployeeWithGeneratedID(db querier, emp Employee) (int, error) {
+	result, err := db.Exec(`INSERT INTO employees (name, position, salary_cents, department, hire_date, manager_id,
+		uuid, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, `+sqlNow+`, `+sqlNow+`)`,
+		emp.Name, emp.Position, emp.Salary, emp.Department, emp.HireDate, emp.ManagerID, emp.uuidValue())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// The UUID to store, NULL when there is none so the unique index ignores it
+func (emp Employee) uuidValue() sql.NullString {
+	return sql.NullString{String: emp.UUID, Valid: emp.UUID != ""}
+}
+
+// Look up the ID of the employee with the given UUID
+func getEmployeeIDByUUID(db database, uuid string) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM employees WHERE uuid = ?", uuid).Scan(&id)
+	return id, err
+}
+
+// Copy the employee under an ID from the generator
+func cloneEmployee(db database, ids *idGenerator, id int) (Employee, error) {
+	employee, err := getEmployeeById(db, id)
+	if err != nil {
+		return Employee{}, err
+	}
+	employee.Name += " (copy)"
+	employee.UUID = ""
+	employee, err = ids.create(db, employee)
+	if err != nil {
+		return Employee{}, err
+	}
+	return getEmployeeById(db, employee.ID)
+}
+
+// Columns that can be changed through updateEmployee
+var updatableColumns = map[string]bool{
+	"name":         true,
+	"position":     true,
+	"salary_cents": true,
+	"department":   true,
+	"hire_date":    true,
+	"manager_id":   true,
+}
+
+// All the mutable fields of the employee keyed by column
+func (emp Employee) columnValues() map[string]interface{} {
+	return map[string]interface{}{
+		"name":         emp.Name,
+		"position":     emp.Position,
+		"salary_cents": emp.Salary,
+		"department":   emp.Department,
+		"hire_date":    string(emp.HireDate),
+		"manager_id":   emp.ManagerID,
+	}
+}
+
+// Build a parameterized UPDATE setting the given columns of one employee and
+// bumping its version. A non-zero expectedVersion only matches the row if it
+// still has that version. Columns are sorted so the same fields always
+// produce the same statement.
+func buildEmployeeUpdate(id int, expectedVersion int, fields map[string]interface{}) (string, []interface{}, error) {
+	if len(fields) == 0 {
+		return "", nil, errors.New("no fields to update")
+	}
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !updatableColumns[column] {
+			return "", nil, fmt.Errorf("column %q cannot be updated", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	assignments := make([]string, len(columns))
+	args := make([]interface{}, 0, len(columns)+1)
+	for i, column := range columns {
+		assignments[i] = column + " = ?"
+		args = append(args, fields[column])
+	}
+	assignments = append(assignments, "version = version + 1", "updated_at = "+sqlNow)
+	query := "UPDATE employees set " + strings.Join(assignments, ", ") + " where id = ?"
+	args = append(args, id)
+	if expectedVersion != 0 {
+		query += " and version = ?"
+		args = append(args, expectedVersion)
+	}
+	return query, args, nil
+}
+
+// Update the given columns of the employee. With a non-zero expectedVersion
+// ErrVersionConflict is returned if the employee was changed since.
+func updateEmployee(db querier, id int, expectedVersion int, fields map[string]interface{}) error {
+	query, args, err := buildEmployeeUpdate(id, expectedVersion, fields)
+	if err != nil {
+		return err
+	}
 	// Check if employee with this ID exists
-	_, err := getEmployeeById(db, emp.ID)
+	_, err = getEmployeeById(db, id)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec("UPDATE employees set name = ?, position = ?, salary = ? where id = ?",
-		emp.Name, emp.Position, emp.Salary, emp.ID)
-	return err
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// Update only the given columns of the employee, leaving the others as stored
+func patchEmployee(db querier, id int, fields map[string]interface{}) error {
+	return updateEmployee(db, id, 0, fields)
+}
+
+// Update the employee like updateEmployee, returning its state before and
+// after the update as read within the same transaction
+func updateEmployeeReturningPrevious(db database, id int, expectedVersion int,
+	fields map[string]interface{}) (Employee, Employee, error) {
+	tx, err := begin(db)
+	if err != nil {
+		return Employee{}, Employee{}, err
+	}
+	defer tx.Rollback()
+
+	previous, err := getEmployeeById(tx, id)
+	if err != nil {
+		return Employee{}, Employee{}, err
+	}
+	if err := updateEmployee(tx, id, expectedVersion, fields); err != nil {
+		return Employee{}, Employee{}, err
+	}
+	current, err := getEmployeeById(tx, id)
+	if err != nil {
+		return Employee{}, Employee{}, err
+	}
+	return previous, current, tx.Commit()
+}
+
+// Apply change to the current state of the employee and save it, re-reading
+// and re-applying it when another write got in first, up to attempts times.
+// Meant for server-side bulk operations that must not lose concurrent edits.
+func updateEmployeeWithRetry(db database, id int, attempts int, change func(*Employee) error) (Employee, error) {
+	for attempt := 0; attempt < attempts; attempt++ {
+		employee, err := getEmployeeById(db, id)
+		if err != nil {
+			return Employee{}, err
+		}
+		if err := change(&employee); err != nil {
+			return Employee{}, err
+		}
+		err = updateEmployee(db, id, employee.Version, employee.columnValues())
+		if errors.Is(err, ErrVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return Employee{}, err
+		}
+		return getEmployeeById(db, id)
+	}
+	return Employee{}, ErrVersionConflict
 }
 
 // Delete the employee
-func deleteEmployee(db *sql.DB, id int) error {
+// An employee managing others cannot be deleted unless reassign is set, which
+// moves their reports to the deleted employee's own manager. Returns the IDs
+// of the reassigned employees.
+func deleteEmployee(db database, id int, reassign bool) ([]int, error) {
+	tx, err := begin(db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	// Check if employee with this ID exists
-	_, err := getEmployeeById(db, id)
+	employee, err := getEmployeeById(tx, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = db.Exec("DELETE from employees where id = ?", id)
-	return err
+	reports, err := getReportIDs(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) > 0 {
+		if !reassign {
+			return nil, ErrManagerHasReports
+		}
+		if _, err := tx.Exec("UPDATE employees SET manager_id = ?, version = version + 1, updated_at = "+sqlNow+
+			" WHERE manager_id = ?", employee.ManagerID, id); err != nil {
+			return nil, err
+		}
+	}
+	// A concurrent delete may have removed the row since it was read
+	result, err := tx.Exec("DELETE from employees where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+	return reports, tx.Commit()
+}
+
+// IDs of the employees managed by the employee
+func getReportIDs(db querier, id int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM employees WHERE manager_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var reportID int
+		if err := rows.Scan(&reportID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, reportID)
+	}
+	return ids, rows.Err()
 }
 
 // Get employee by Id
-func getEmployeeById(db *sql.DB, id int) (Employee, error) {
-	var employee Employee
-	row := db.QueryRow("SELECT id, name, position, salary from employees where id = ?", id)
-	err := row.Scan(&employee.ID, &employee.Name, &employee.Position, &employee.Salary)
+func getEmployeeById(db querier, id int) (Employee, error) {
+	row := db.QueryRow("SELECT "+employeeColumns+" from employees where id = ?", id)
+	employee, err := scanEmployee(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Employee{}, ErrEmployeeNotFound
+	}
 	if err != nil {
 		return Employee{}, err
 	}
 	return employee, nil
 }
 
+// EmployeeName Struct:
+// The ID and name of an employee, for pickers that need no more.
+type EmployeeName struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// List only the IDs and names of the employees matching the filter, along
+// with the total number matching it
+func getEmployeeNames(db database, filter EmployeeFilter, order EmployeeSort, size int, offset int) ([]EmployeeName, int, error) {
+	where, args := filter.where()
+	args = append(args, size, offset)
+	rows, err := db.Query("SELECT id, name FROM employees"+where+order.orderBy()+" LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	names := []EmployeeName{}
+	for rows.Next() {
+		var name EmployeeName
+		if err := rows.Scan(&name.ID, &name.Name); err != nil {
+			return nil, 0, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	total, err := countEmployees(db, filter)
+	return names, total, err
+}
+
 // List the employees
-func getEmployeesList(db *sql.DB, size int, offset int) ([]Employee, error) {
-	var employees []Employee
-	rows, err := db.Query("SELECT id, name, position, salary FROM employees ORDER BY ID asc LIMIT ? OFFSET ? ", size, offset)
+func getEmployeesList(db database, filter EmployeeFilter, order EmployeeSort, size int, offset int) ([]Employee, error) {
+	query, args := employeesListQuery(filter, order, size, offset)
+	return queryEmployees(db, query, args...)
+}
+
+// List the employees of one page along with the total number matching the
+// filter. With useWindow the total is computed by the page query itself with
+// COUNT(*) OVER(), saving the separate COUNT query.
+func getEmployeesPage(db database, filter EmployeeFilter, order EmployeeSort, size int, offset int, useWindow bool) ([]Employee, int, error) {
+	if !useWindow {
+		employees, err := getEmployeesList(db, filter, order, size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		total, err := countEmployees(db, filter)
+		return employees, total, err
+	}
+
+	where, args := filter.where()
+	args = append(args, size, offset)
+	employees := []Employee{}
+	total := 0
+	rows, err := db.Query("SELECT "+employeeColumns+", COUNT(*) OVER() FROM employees"+where+
+		order.orderBy()+" LIMIT ? OFFSET ? ", args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var employee Employee
-		if err := rows.Scan(&employee.ID, &employee.Name, &employee.Position, &employee.Salary); err != nil {
-			return nil, err
+		employee, err := scanEmployee(withExtraColumns(rows, &total))
+		if err != nil {
+			return nil, 0, err
 		}
 		employees = append(employees, employee)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	// A page past the end has no row to carry the total
+	if len(employees) == 0 && offset > 0 {
+		total, err = countEmployees(db, filter)
+	}
+	return employees, total, err
+}
+
+// Count the employees matching the filter
+func countEmployees(db database, filter EmployeeFilter) (int, error) {
+	where, args := filter.where()
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM employees"+where, args...).Scan(&total)
+	return total, err
+}
+
+// Add up the salaries of every employee matching the filter
+func sumSalaries(db database, filter EmployeeFilter) (Cents, error) {
+	where, args := filter.where()
+	var total Cents
+	err := db.QueryRow("SELECT COALESCE(SUM(salary_cents), 0) FROM employees"+where, args...).Scan(&total)
+	return total, err
+}
+
+// Scans the columns of an employee followed by extra columns into dest
+type extraColumnsScanner struct {
+	row  rowScanner
+	dest []interface{}
+}
+
+func withExtraColumns(row rowScanner, dest ...interface{}) rowScanner {
+	return extraColumnsScanner{row: row, dest: dest}
+}
+
+func (e extraColumnsScanner) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.dest...)...)
+}
+
+// Call fn for each employee of the list as rows are read, without holding
+// the whole list in memory
+func streamEmployeesList(db database, filter EmployeeFilter, order EmployeeSort, size int, offset int, fn func(Employee) error) error {
+	query, args := employeesListQuery(filter, order, size, offset)
+	return forEachEmployee(db, fn, query, args...)
+}
+
+// Build the list query, a negative size means no limit
+func employeesListQuery(filter EmployeeFilter, order EmployeeSort, size int, offset int) (string, []interface{}) {
+	where, args := filter.where()
+	args = append(args, size, offset)
+	return "SELECT " + employeeColumns + " FROM employees" + where + order.orderBy() + " LIMIT ? OFFSET ? ", args
+}
+
+// List the employees hired in the given year
+func getEmployeesByHireYear(db database, year string, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, "strftime('%Y', hire_date) = ?", []interface{}{year}, size, offset)
+}
+
+// List the employees whose name starts with the letter, in either case.
+// Both cases are matched explicitly since LIKE only folds ASCII letters.
+func getEmployeesByInitial(db database, letter string, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, "(name LIKE ? OR name LIKE ?)",
+		[]interface{}{strings.ToLower(letter) + "%", strings.ToUpper(letter) + "%"}, size, offset)
+}
+
+// List the employees whose name contains the term, ignoring case
+func searchEmployeesByName(db database, term string, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, `name LIKE ? ESCAPE '\'`,
+		[]interface{}{"%" + likeEscaper.Replace(term) + "%"}, size, offset)
+}
+
+// List the employees earning exactly the salary
+func getEmployeesBySalary(db database, salary Cents, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, "salary_cents = ?", []interface{}{salary}, size, offset)
+}
+
+// List the employees earning more than the average salary
+func getEmployeesAboveAverage(db database, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, "salary_cents > (SELECT AVG(salary_cents) FROM employees)", nil,
+		size, offset)
+}
+
+// Mean and standard deviation of every salary
+type SalaryStats struct {
+	Mean   Cents `json:"mean"`
+	StdDev Cents `json:"stdDev"`
+}
+
+// List the employees whose salary is more than deviations standard deviations
+// from the mean. The mean and the variance, as the mean of the squares less
+// the square of the mean, come from a single scan of the salaries.
+func getSalaryOutliers(db database, deviations float64, size int, offset int) ([]Employee, int, SalaryStats, error) {
+	var mean, meanOfSquares float64
+	err := db.QueryRow(`SELECT COALESCE(AVG(salary_cents), 0), COALESCE(AVG(salary_cents * 1.0 * salary_cents), 0)
+		FROM employees`).Scan(&mean, &meanOfSquares)
+	if err != nil {
+		return nil, 0, SalaryStats{}, err
+	}
+	stdDev := math.Sqrt(math.Max(meanOfSquares-mean*mean, 0))
+	stats := SalaryStats{Mean: Cents(math.Round(mean)), StdDev: Cents(math.Round(stdDev))}
+
+	employees, total, err := getEmployeesPageWhere(db, "ABS(salary_cents - ?) > ?",
+		[]interface{}{mean, deviations * stdDev}, size, offset)
+	return employees, total, stats, err
+}
+
+// List the employees changed most recently first, by their last update or
+// their creation when never updated. Rows without timestamps come last.
+func getRecentEmployees(db database, limit int) ([]Employee, error) {
+	return queryEmployees(db, "SELECT "+employeeColumns+" FROM employees"+
+		" ORDER BY COALESCE(updated_at, created_at) DESC, id asc LIMIT ?", limit)
+}
+
+// RankedEmployee Struct:
+// An employee with their position in the salary ranking.
+type RankedEmployee struct {
+	Employee
+	//Dense rank by salary, highest first. Equal salaries share a rank and the
+	//next salary gets the following one.
+	Rank int `json:"rank"`
+}
+
+// List one page of the employees ranked by salary, highest first, along with
+// the total number of employees. Ranks are computed over every employee, so
+// they do not restart on each page.
+func getRankedEmployees(db database, size int, offset int) ([]RankedEmployee, int, error) {
+	rows, err := db.Query("SELECT "+employeeColumns+", DENSE_RANK() OVER (ORDER BY salary_cents DESC)"+
+		" FROM employees ORDER BY salary_cents DESC, id asc LIMIT ? OFFSET ?", size, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
 
+	ranked := []RankedEmployee{}
+	for rows.Next() {
+		var rank int
+		employee, err := scanEmployee(withExtraColumns(rows, &rank))
+		if err != nil {
+			return nil, 0, err
+		}
+		ranked = append(ranked, RankedEmployee{Employee: employee, Rank: rank})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	total, err := countEmployees(db, EmployeeFilter{})
+	return ranked, total, err
+}
+
+// List the employees without a manager
+func getUnmanagedEmployees(db database, size int, offset int) ([]Employee, int, error) {
+	return getEmployeesPageWhere(db, "manager_id IS NULL", nil, size, offset)
+}
+
+// List one page of the employees matching the condition by ID, along with
+// the total number matching it
+func getEmployeesPageWhere(db database, condition string, args []interface{}, size int, offset int) ([]Employee, int, error) {
+	pageArgs := append(append([]interface{}{}, args...), size, offset)
+	employees, err := queryEmployees(db, "SELECT "+employeeColumns+" FROM employees WHERE "+condition+
+		" ORDER BY ID asc LIMIT ? OFFSET ?", pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	err = db.QueryRow("SELECT COUNT(*) FROM employees WHERE "+condition, args...).Scan(&total)
+	return employees, total, err
+}
+
+// Run a query selecting employeeColumns, returning an empty list when nothing matches
+func queryEmployees(db database, query string, args ...interface{}) ([]Employee, error) {
+	employees := []Employee{}
+	err := forEachEmployee(db, func(employee Employee) error {
+		employees = append(employees, employee)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
 	return employees, nil
 }
+
+// Run a query selecting employeeColumns and call fn for each row
+func forEachEmployee(db database, fn func(Employee) error, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		employee, err := scanEmployee(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(employee); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Tag the employee, creating the tag if it does not exist yet
+func addEmployeeTag(db database, id int, tag string) error {
+	// Check if employee with this ID exists
+	_, err := getEmployeeById(db, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := begin(db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT OR IGNORE INTO employee_tags (employee_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?`, id, tag)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remove the tag from the employee
+func removeEmployeeTag(db database, id int, tag string) error {
+	result, err := db.Exec(`DELETE FROM employee_tags WHERE employee_id = ?
+		AND tag_id = (SELECT id FROM tags WHERE name = ?)`, id, tag)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Get the tags of an employee
+func getEmployeeTags(db database, id int) ([]string, error) {
+	tags := []string{}
+	rows, err := db.Query(`SELECT t.name FROM tags t JOIN employee_tags et ON et.tag_id = t.id
+		WHERE et.employee_id = ? ORDER BY t.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// IntegrityReport Struct:
+type IntegrityReport struct {
+	//Whether no issue was found.
+	OK bool `json:"ok"`
+	//Output of PRAGMA integrity_check, ["ok"] for a healthy database.
+	IntegrityCheck []string `json:"integrityCheck"`
+	//Rows referencing a parent row that does not exist.
+	ForeignKeyViolations []ForeignKeyViolation `json:"foreignKeyViolations"`
+}
+
+// ForeignKeyViolation Struct:
+type ForeignKeyViolation struct {
+	//Table holding the dangling reference.
+	Table string `json:"table"`
+	//Rowid of the row holding the dangling reference.
+	RowID int64 `json:"rowId"`
+	//Table the missing row was expected in.
+	Parent string `json:"parent"`
+}
+
+// DeadLetter Struct:
+// A webhook event whose delivery failed, kept until a retry delivers it.
+type DeadLetter struct {
+	//ID of the dead letter.
+	ID int
+	//The event as it is POSTed to the webhook.
+	Event []byte
+	//Failed deliveries, the first one with its retries counting as one.
+	Attempts int
+}
+
+// Keep the event of a failed webhook delivery
+func addDeadLetter(db database, event []byte, reason error) error {
+	_, err := db.Exec("INSERT INTO dead_letter (event, last_error, failed_at) VALUES (?, ?, "+sqlNow+")",
+		string(event), reason.Error())
+	return err
+}
+
+// The dead letters, oldest first
+func getDeadLetters(db database) ([]DeadLetter, error) {
+	rows, err := db.Query("SELECT id, event, attempts FROM dead_letter ORDER BY id asc")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		var letter DeadLetter
+		var event string
+		if err := rows.Scan(&letter.ID, &event, &letter.Attempts); err != nil {
+			return nil, err
+		}
+		letter.Event = []byte(event)
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// Record another failed attempt at delivering the dead letter
+func failDeadLetter(db database, id int, reason error) error {
+	_, err := db.Exec("UPDATE dead_letter SET attempts = attempts + 1, last_error = ?, failed_at = "+sqlNow+
+		" WHERE id = ?", reason.Error(), id)
+	return err
+}
+
+// Remove the dead letter once it is delivered
+func deleteDeadLetter(db database, id int) error {
+	_, err := db.Exec("DELETE FROM dead_letter WHERE id = ?", id)
+	return err
+}
+
+// Check the database file and look for dangling references, such as join rows
+// left behind by writes made while foreign keys were not enforced
+func checkIntegrity(db database) (IntegrityReport, error) {
+	report := IntegrityReport{IntegrityCheck: []string{}, ForeignKeyViolations: []ForeignKeyViolation{}}
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return report, err
+		}
+		report.IntegrityCheck = append(report.IntegrityCheck, line)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	rows.Close()
+
+	rows, err = db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var violation ForeignKeyViolation
+		var fkid int
+		if err := rows.Scan(&violation.Table, &violation.RowID, &violation.Parent, &fkid); err != nil {
+			return report, err
+		}
+		report.ForeignKeyViolations = append(report.ForeignKeyViolations, violation)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	report.OK = len(report.ForeignKeyViolations) == 0 &&
+		len(report.IntegrityCheck) == 1 && report.IntegrityCheck[0] == "ok"
+	return report, nil
+}
+
+// Read-only queries run by GET /admin/diagnostics, each returning a single
+// number. Add an entry to report another figure.
+var diagnosticQueries = []struct {
+	name  string
+	query string
+}{
+	{"rowCount", "SELECT COUNT(*) FROM employees"},
+	{"maxId", "SELECT COALESCE(MAX(id), 0) FROM employees"},
+	{"nullNameCount", "SELECT COUNT(*) FROM employees WHERE name IS NULL OR TRIM(name) = ''"},
+}
+
+// Run every diagnostic query, keyed by name
+func runDiagnostics(db querier) (map[string]int64, error) {
+	report := make(map[string]int64, len(diagnosticQueries))
+	for _, diagnostic := range diagnosticQueries {
+		var value int64
+		if err := db.QueryRow(diagnostic.query).Scan(&value); err != nil {
+			return nil, fmt.Errorf("diagnostic %s: %w", diagnostic.name, err)
+		}
+		report[diagnostic.name] = value
+	}
+	return report, nil
+}
+
+// Set the manager of all the given employees in one transaction
+func assignManager(db database, managerID int, employeeIDs []int) error {
+	tx, err := begin(db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Check the manager and every employee exist
+	for _, id := range append([]int{managerID}, employeeIDs...) {
+		var exists int
+		if err := tx.QueryRow("SELECT 1 FROM employees WHERE id = ?", id).Scan(&exists); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("employee %d: %w", id, ErrEmployeeNotFound)
+			}
+			return err
+		}
+	}
+
+	for _, id := range employeeIDs {
+		if _, err := tx.Exec("UPDATE employees SET manager_id = ?, version = version + 1, updated_at = "+sqlNow+
+			" WHERE id = ?", managerID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Exchange the positions of two employees in one transaction, returning both
+// as they are after the swap
+func swapPositions(db database, a int, b int) (Employee, Employee, error) {
+	tx, err := begin(db)
+	if err != nil {
+		return Employee{}, Employee{}, err
+	}
+	defer tx.Rollback()
+
+	first, err := getEmployeeById(tx, a)
+	if err != nil {
+		return Employee{}, Employee{}, fmt.Errorf("employee %d: %w", a, err)
+	}
+	second, err := getEmployeeById(tx, b)
+	if err != nil {
+		return Employee{}, Employee{}, fmt.Errorf("employee %d: %w", b, err)
+	}
+	for id, position := range map[int]string{a: second.Position, b: first.Position} {
+		if _, err := tx.Exec("UPDATE employees SET position = ?, version = version + 1, updated_at = "+sqlNow+
+			" WHERE id = ?", position, id); err != nil {
+			return Employee{}, Employee{}, err
+		}
+	}
+
+	if first, err = getEmployeeById(tx, a); err != nil {
+		return Employee{}, Employee{}, err
+	}
+	if second, err = getEmployeeById(tx, b); err != nil {
+		return Employee{}, Employee{}, err
+	}
+	return first, second, tx.Commit()
+}
+
+// Rename the department on every employee in it, returning how many changed
+func renameDepartment(db database, from string, to string) (int64, error) {
+	result, err := db.Exec("UPDATE employees SET department = ?, version = version + 1, updated_at = "+sqlNow+
+		" WHERE department = ?", to, from)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DepartmentSalaries Struct:
+// Salary aggregates of the employees in one department.
+type DepartmentSalaries struct {
+	//Name of the department, empty for employees without one.
+	Department string `json:"department"`
+	//Sum of the salaries.
+	Total Cents `json:"total"`
+	//Mean salary, rounded to the cent.
+	Average Cents `json:"average"`
+	//Number of employees in the department.
+	Count int `json:"count"`
+}
+
+// Aggregate the salaries per department, highest total first
+func getDepartmentSalaries(db database) ([]DepartmentSalaries, error) {
+	rows, err := db.Query(`SELECT COALESCE(department, ''), SUM(salary_cents),
+		CAST(ROUND(AVG(salary_cents)) AS INTEGER), COUNT(*)
+		FROM employees GROUP BY COALESCE(department, '')
+		ORDER BY SUM(salary_cents) DESC, COALESCE(department, '')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	departments := []DepartmentSalaries{}
+	for rows.Next() {
+		var department DepartmentSalaries
+		if err := rows.Scan(&department.Department, &department.Total,
+			&department.Average, &department.Count); err != nil {
+			return nil, err
+		}
+		departments = append(departments, department)
+	}
+	return departments, rows.Err()
+}
+
+// SalaryBand Struct:
+// Number of employees earning within one salary band.
+type SalaryBand struct {
+	//Lowest salary of the band, inclusive.
+	Min Cents `json:"min"`
+	//Highest salary of the band, exclusive. Null for the top band.
+	Max *Cents `json:"max"`
+	//Number of employees in the band.
+	Count int `json:"count"`
+}
+
+// Count the employees per salary band. bounds are the ascending upper bounds
+// of every band but the last, which is open ended.
+func getSalaryBands(db database, bounds []Cents) ([]SalaryBand, error) {
+	bands := make([]SalaryBand, len(bounds)+1)
+	var cases strings.Builder
+	args := make([]interface{}, len(bounds))
+	for i := range bounds {
+		fmt.Fprintf(&cases, " WHEN salary_cents < ? THEN %d", i)
+		args[i] = bounds[i]
+		bands[i].Max = &bounds[i]
+		bands[i+1].Min = bounds[i]
+	}
+
+	rows, err := db.Query("SELECT CASE"+cases.String()+" ELSE "+strconv.Itoa(len(bounds))+
+		" END AS band, COUNT(*) FROM employees GROUP BY band", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var band, count int
+		if err := rows.Scan(&band, &count); err != nil {
+			return nil, err
+		}
+		bands[band].Count = count
+	}
+	return bands, rows.Err()
+}
+
+// Create the employee or replace it if the ID exists, returning the stored
+// employee and whether it was created
+func upsertEmployee(db database, emp Employee) (Employee, bool, error) {
+	tx, err := begin(db)
+	if err != nil {
+		return Employee{}, false, err
+	}
+	defer tx.Rollback()
+
+	_, err = getEmployeeById(tx, emp.ID)
+	created := errors.Is(err, ErrEmployeeNotFound)
+	if err != nil && !created {
+		return Employee{}, false, err
+	}
+	if created {
+		err = createEmployee(tx, emp)
+	} else {
+		err = updateEmployee(tx, emp.ID, emp.Version, emp.columnValues())
+	}
+	if err != nil {
+		return Employee{}, false, err
+	}
+
+	stored, err := getEmployeeById(tx, emp.ID)
+	if err != nil {
+		return Employee{}, false, err
+	}
+	return stored, created, tx.Commit()
+}
+
+// Drop and recreate the employee tables, then insert the seed employees
+func resetDatabase(db *sql.DB, seed []Employee) error {
+	for _, table := range []string{"employee_tags", "tags", "employees"} {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			return err
+		}
+	}
+	if err := initSchema(db); err != nil {
+		return err
+	}
+	for _, emp := range seed {
+		if err := createEmployee(db, emp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returned when a salary recomputation would take a salary out of the allowed range
+var ErrSalaryOutOfRange = errors.New("recomputed salary out of the allowed range")
+
+// SalaryRecompute Struct:
+// A change applied to the salaries of every matching employee.
+type SalaryRecompute struct {
+	//Operation from salaryOperations, e.g. multiply.
+	Op string `json:"op"`
+	//Factor salaries are multiplied by, for multiply.
+	Factor float64 `json:"factor"`
+	//Amount added to salaries, negative to subtract, for add.
+	Amount Cents `json:"amount"`
+	//Which employees are changed, all when empty.
+	Filter SalaryRecomputeFilter `json:"filter"`
+}
+
+// SalaryRecomputeFilter Struct:
+type SalaryRecomputeFilter struct {
+	//Only change employees in this department.
+	Department string `json:"department"`
+}
+
+// The operations a recomputation can apply, mapped to the SQL computing the
+// new salary from one argument. Only these expressions reach the SQL text.
+var salaryOperations = map[string]string{
+	"multiply": "CAST(ROUND(salary_cents * ?) AS INTEGER)",
+	"add":      "salary_cents + ?",
+}
+
+// Argument of the operation's SQL expression
+func (job SalaryRecompute) argument() interface{} {
+	if job.Op == "multiply" {
+		return job.Factor
+	}
+	return job.Amount
+}
+
+// Count the employees the job matches and, unless preview is set, apply it in
+// one transaction. Nothing is changed when a new salary falls outside min and max.
+func recomputeSalaries(db database, job SalaryRecompute, preview bool, min Cents, max Cents) (int, error) {
+	expression, ok := salaryOperations[job.Op]
+	if !ok {
+		return 0, fmt.Errorf("unknown operation %q", job.Op)
+	}
+	where := ""
+	var args []interface{}
+	if job.Filter.Department != "" {
+		where = " WHERE department = ?"
+		args = append(args, job.Filter.Department)
+	}
+
+	tx, err := begin(db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var matched int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM employees"+where, args...).Scan(&matched); err != nil {
+		return 0, err
+	}
+	if preview {
+		return matched, nil
+	}
+
+	_, err = tx.Exec("UPDATE employees SET salary_cents = "+expression+
+		", version = version + 1, updated_at = "+sqlNow+where, append([]interface{}{job.argument()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	var outOfRange int
+	query := "SELECT COUNT(*) FROM employees WHERE (salary_cents < ? OR salary_cents > ?)"
+	if job.Filter.Department != "" {
+		query += " AND department = ?"
+	}
+	if err := tx.QueryRow(query, append([]interface{}{min, max}, args...)...).Scan(&outOfRange); err != nil {
+		return 0, err
+	}
+	if outOfRange > 0 {
+		return 0, ErrSalaryOutOfRange
+	}
+	return matched, tx.Commit()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// Number of statements run through the sqlite3_counting driver
+var countedQueries int64
+
+// SQLite driver counting the statements it runs. Its connections only
+// expose Prepare, so database/sql prepares every query and exec.
+type countingDriver struct{}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&countedQueries, 1)
+	return c.Conn.Prepare(query)
+}
+
+func init() {
+	sql.Register("sqlite3_counting", countingDriver{})
+}
+
+// Run fn and return how many statements it ran
+func countQueries(fn func()) int64 {
+	before := atomic.LoadInt64(&countedQueries)
+	fn()
+	return atomic.LoadInt64(&countedQueries) - before
+}
+
+func TestInitSchema_PASS_Migrates_Old_Table(t *testing.T) {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	// Table as created by the first release
+	db.Exec(`CREATE TABLE employees (ID INTEGER PRIMARY KEY, Name TEXT, Position TEXT, Salary REAL)`)
+	db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (2, 'Alice', 'Manager', 60000)")
+
+	assert.Nil(t, initSchema(db))
+	// Running it again is a no-op
+	assert.Nil(t, initSchema(db))
+
+	employee, err := getEmployeeById(db, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", employee.Name)
+	assert.Equal(t, "", employee.Department)
+	assert.Equal(t, Cents(60000_00), employee.Salary)
+
+	// The REAL salary column is replaced by salary_cents
+	legacy, _ := hasColumn(db, "employees", "Salary")
+	assert.False(t, legacy)
+}
+
+func TestBuildEmployeeUpdate_PASS_Field_Subsets(t *testing.T) {
+	tests := []struct {
+		fields map[string]interface{}
+		query  string
+		args   []interface{}
+	}{
+		{
+			fields: map[string]interface{}{"salary_cents": Cents(70000_00)},
+			query:  "UPDATE employees set salary_cents = ?, version = version + 1, updated_at = " + sqlNow + " where id = ?",
+			args:   []interface{}{Cents(70000_00), 2},
+		},
+		{
+			fields: map[string]interface{}{"position": "Lead", "name": "Alice"},
+			query:  "UPDATE employees set name = ?, position = ?, version = version + 1, updated_at = " + sqlNow + " where id = ?",
+			args:   []interface{}{"Alice", "Lead", 2},
+		},
+		{
+			fields: Employee{Name: "Alice", Position: "Lead", Salary: 1, Department: "Eng", HireDate: "2021-03-15"}.columnValues(),
+			query:  "UPDATE employees set department = ?, hire_date = ?, manager_id = ?, name = ?, position = ?, salary_cents = ?, version = version + 1, updated_at = " + sqlNow + " where id = ?",
+			args:   []interface{}{"Eng", "2021-03-15", (*int)(nil), "Alice", "Lead", Cents(1), 2},
+		},
+	}
+
+	for _, test := range tests {
+		query, args, err := buildEmployeeUpdate(2, 0, test.fields)
+		assert.Nil(t, err)
+		assert.Equal(t, test.query, query)
+		assert.Equal(t, test.args, args)
+	}
+}
+
+func TestBuildEmployeeUpdate_FAIL_Column_Not_Allowed(t *testing.T) {
+	_, _, err := buildEmployeeUpdate(2, 0, map[string]interface{}{"id = 1; DROP TABLE employees; --": 1})
+	assert.NotNil(t, err)
+
+	_, _, err = buildEmployeeUpdate(2, 0, map[string]interface{}{"id": 5})
+	assert.NotNil(t, err)
+}
+
+func TestBuildEmployeeUpdate_FAIL_No_Fields(t *testing.T) {
+	_, _, err := buildEmployeeUpdate(2, 0, map[string]interface{}{})
+	assert.NotNil(t, err)
+}
+
+func TestUpdateEmployee_PASS_Only_Given_Fields_Change(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	err := updateEmployee(db, 2, 0, map[string]interface{}{"salary_cents": Cents(65000_00)})
+	assert.Nil(t, err)
+
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, Cents(65000_00), employee.Salary)
+	assert.Equal(t, "Alice", employee.Name)
+	assert.Equal(t, "Manager", employee.Position)
+}
+
+func TestGetEmployeeById_FAIL_Not_Found(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	_, err := getEmployeeById(db, 22)
+	assert.True(t, errors.Is(err, ErrEmployeeNotFound))
+}
+
+func TestGetEmployeesPage_PASS_Window_Total_Single_Query(t *testing.T) {
+	db := setupDatabaseWithDriver("sqlite3_counting")
+	defer db.Close()
+	addEmployeeTag(db, 2, "remote")
+	addEmployeeTag(db, 3, "remote")
+	addEmployeeTag(db, 4, "remote")
+
+	var employees []Employee
+	var total int
+	var err error
+	queries := countQueries(func() {
+		employees, total, err = getEmployeesPage(db, EmployeeFilter{Tag: "remote"}, EmployeeSort{}, 2, 0, true)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), queries)
+	assert.Equal(t, 2, len(employees))
+	assert.Equal(t, 3, total)
+}
+
+func TestGetEmployeesPage_PASS_Count_Total_Two_Queries(t *testing.T) {
+	db := setupDatabaseWithDriver("sqlite3_counting")
+	defer db.Close()
+
+	var employees []Employee
+	var total int
+	var err error
+	queries := countQueries(func() {
+		employees, total, err = getEmployeesPage(db, EmployeeFilter{}, EmployeeSort{}, 2, 0, false)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), queries)
+	assert.Equal(t, 2, len(employees))
+	assert.Equal(t, 4, total)
+}
+
+func TestGetEmployeesPage_PASS_Window_Total_Past_Last_Page(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	employees, total, err := getEmployeesPage(db, EmployeeFilter{}, EmployeeSort{}, 10, 100, true)
+
+	assert.Nil(t, err)
+	assert.Empty(t, employees)
+	assert.Equal(t, 4, total)
+}
+
+func TestBuildEmployeeUpdate_PASS_Expected_Version(t *testing.T) {
+	query, args, err := buildEmployeeUpdate(2, 3, map[string]interface{}{"name": "Alice"})
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE employees set name = ?, version = version + 1, updated_at = "+sqlNow+" where id = ? and version = ?", query)
+	assert.Equal(t, []interface{}{"Alice", 2, 3}, args)
+}
+
+func TestUpdateEmployee_FAIL_Stale_Version(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	// The first update moves the employee to version 2
+	assert.Nil(t, updateEmployee(db, 2, 1, map[string]interface{}{"name": "Alice Smith"}))
+	err := updateEmployee(db, 2, 1, map[string]interface{}{"name": "Alice Jones"})
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice Smith", employee.Name)
+	assert.Equal(t, 2, employee.Version)
+}
+
+func TestUpdateEmployeeWithRetry_PASS_After_One_Conflict(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	calls := 0
+	employee, err := updateEmployeeWithRetry(db, 2, 3, func(emp *Employee) error {
+		calls++
+		if calls == 1 {
+			// Someone else updates the employee between our read and write
+			updateEmployee(db, 2, 0, map[string]interface{}{"position": "Director"})
+		}
+		emp.Salary += 1000_00
+		return nil
+	})
+
+	// Check the change was re-applied on top of the concurrent one
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	stored, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Director", stored.Position)
+	assert.Equal(t, Cents(61000_00), stored.Salary)
+	assert.Equal(t, 3, stored.Version)
+	assert.Equal(t, stored, employee)
+}
+
+func TestUpdateEmployeeWithRetry_FAIL_Gives_Up(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	_, err := updateEmployeeWithRetry(db, 2, 2, func(emp *Employee) error {
+		// Every attempt loses to a concurrent update
+		updateEmployee(db, 2, 0, map[string]interface{}{"position": "Director"})
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+type queryCounterKey struct{}
+
+// Number of statements a request ran, kept in its context
+type queryCounter struct {
+	count int64
+}
+
+// A copy of ctx counting the statements run with it
+func withQueryCounter(ctx context.Context) (context.Context, *queryCounter) {
+	counter := &queryCounter{}
+	return context.WithValue(ctx, queryCounterKey{}, counter), counter
+}
+
+// Count one statement against the request of ctx, if it is being counted
+func countQuery(ctx context.Context) {
+	if counter, ok := ctx.Value(queryCounterKey{}).(*queryCounter); ok {
+		atomic.AddInt64(&counter.count, 1)
+	}
+}
+
+// In debug mode, send the number of statements the request ran before its
+// response started in X-DB-Queries, to spot N+1 patterns. The counter lives
+// in the request context and is bumped by the requestDB of the handler, so
+// concurrent requests and background work never show in each other's count.
+func countQueriesHeader(debug bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !debug {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, counter := withQueryCounter(r.Context())
+			qw := &queryCountWriter{ResponseWriter: w, counter: counter}
+			next.ServeHTTP(qw, r.WithContext(ctx))
+			if !qw.wroteHeader {
+				qw.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
+
+// Adds X-DB-Queries to the response headers when they are written
+type queryCountWriter struct {
+	http.ResponseWriter
+	counter     *queryCounter
+	wroteHeader bool
+}
+
+func (q *queryCountWriter) WriteHeader(status int) {
+	if !q.wroteHeader {
+		q.wroteHeader = true
+		count := atomic.LoadInt64(&q.counter.count)
+		q.Header().Set("X-DB-Queries", strconv.FormatInt(count, 10))
+	}
+	q.ResponseWriter.WriteHeader(status)
+}
+
+func (q *queryCountWriter) Write(p []byte) (int, error) {
+	if !q.wroteHeader {
+		q.WriteHeader(http.StatusOK)
+	}
+	return q.ResponseWriter.Write(p)
+}
+
+func (q *queryCountWriter) Flush() {
+	if flusher, ok := q.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountQueriesHeader_PASS(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id = 3")
+	router := newRouter(newHandler(Config{DebugQueries: true}, db))
+
+	for path, queries := range map[string]string{
+		"/employees/3":                "1",
+		"/employees/3?expand=manager": "2",
+		"/getEmployees":               "2",
+	} {
+		// Create a request through the full router
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		// Check the header counts the queries of the request
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		assert.Equal(t, queries, rr.Header().Get("X-DB-Queries"), path)
+	}
+}
+
+func TestCountQueriesHeader_PASS_Concurrent(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id = 3")
+	server := httptest.NewServer(newRouter(newHandler(Config{DebugQueries: true}, db)))
+	defer server.Close()
+
+	// An open event stream does not hold up the other requests
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/employees/stream", nil)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	defer stream.Body.Close()
+	assert.Equal(t, "0", stream.Header.Get("X-DB-Queries"))
+
+	// Each request only counts its own queries, not those of the requests
+	// running alongside it or of work outside any request
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Exec("UPDATE employees SET version = version WHERE id = 4")
+			resp, err := http.Get(server.URL + "/employees/3?expand=manager")
+			if err != nil {
+				t.Errorf("Error sending request: %v", err)
+				return
+			}
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "2", resp.Header.Get("X-DB-Queries"))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCountQueriesHeader_PASS_Disabled(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	router := newRouter(newHandler(Config{}, db))
+
+	// Create a request through the full router
+	req := httptest.NewRequest("GET", "/employees/2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	// Check the header is only sent in debug mode
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "", rr.Header().Get("X-DB-Queries"))
+}
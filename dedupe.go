@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Remembers recent successful creates so an identical create received within
+// the window, typically a double submit, returns the first result instead of
+// inserting again. A nil deduper is valid and never dedupes.
+type createDeduper struct {
+	window  time.Duration
+	mu      sync.Mutex
+	entries map[string]*dedupeEntry
+	now     func() time.Time
+}
+
+type dedupeEntry struct {
+	// Closed once the first create has finished
+	done chan struct{}
+	// The employee as the first create inserted it, with its assigned ID
+	created Employee
+	err     error
+	// Zero while the first create is in flight
+	expires time.Time
+}
+
+func newCreateDeduper(window time.Duration) *createDeduper {
+	return &createDeduper{window: window, entries: make(map[string]*dedupeEntry), now: time.Now}
+}
+
+// Key identifying identical create payloads
+func dedupeKey(emp Employee) string {
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%d", emp.ID, emp.Name, emp.Position, emp.Salary)
+}
+
+// Run create unless an identical create succeeded within the window or is in
+// flight, in which case wait for it and report a duplicate. Either way the
+// employee the first create inserted is returned.
+func (d *createDeduper) do(key string, create func() (Employee, error)) (Employee, bool, error) {
+	if d == nil {
+		created, err := create()
+		return created, false, err
+	}
+
+	for {
+		d.mu.Lock()
+		d.prune()
+		entry, ok := d.entries[key]
+		if ok {
+			d.mu.Unlock()
+			<-entry.done
+			if entry.err == nil {
+				return entry.created, true, nil
+			}
+			// The first create failed and was forgotten, so try again
+			continue
+		}
+		entry = &dedupeEntry{done: make(chan struct{})}
+		d.entries[key] = entry
+		d.mu.Unlock()
+
+		entry.created, entry.err = create()
+		d.mu.Lock()
+		if entry.err != nil {
+			delete(d.entries, key)
+		} else {
+			entry.expires = d.now().Add(d.window)
+		}
+		close(entry.done)
+		d.mu.Unlock()
+		return entry.created, false, entry.err
+	}
+}
+
+// Drop finished entries whose window has passed. Must hold mu.
+func (d *createDeduper) prune() {
+	now := d.now()
+	for key, entry := range d.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			delete(d.entries, key)
+		}
+	}
+}
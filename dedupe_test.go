@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEmployeeHandler_PASS_Duplicate_Within_Window(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, events: newEventBroker(), creates: newCreateDeduper(time.Minute)}
+	defer handler.db.Close()
+
+	// Fire two identical creates at the same time
+	employee := Employee{ID: 7, Name: "Bob", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+			rr := httptest.NewRecorder()
+			handler.createEmployeeHandler(rr, req)
+			codes[i] = rr.Code
+		}(i)
+	}
+	wg.Wait()
+
+	// Both get the first result and only one insert happened
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated}, codes)
+	assert.Equal(t, 1, handler.events.seq)
+	_, err := getEmployeeById(db, 7)
+	assert.Nil(t, err)
+}
+
+func TestCreateEmployeeHandler_FAIL_Duplicate_Without_Deduper(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Without deduplication the second identical create conflicts
+	employee := Employee{ID: 7, Name: "Bob", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	var codes []int
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+		rr := httptest.NewRecorder()
+		handler.createEmployeeHandler(rr, req)
+		codes = append(codes, rr.Code)
+	}
+	assert.Equal(t, []int{http.StatusCreated, http.StatusConflict}, codes)
+}
+
+func TestCreateEmployeeHandler_PASS_Duplicate_Without_ID_Sequence_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := newHandler(Config{IDStrategy: idStrategySequence, CreateDedupeWindow: time.Minute}, db)
+	defer handler.db.Close()
+
+	// Fire identical creates without an ID at the same time
+	reqBody := []byte(`{"name":"Bob","position":"Engineer","salary":50000}`)
+	codes := make([]int, 3)
+	locations := make([]string, 3)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+			rr := httptest.NewRecorder()
+			handler.createEmployeeHandler(rr, req)
+			codes[i] = rr.Code
+			locations[i] = rr.Header().Get("Location")
+		}(i)
+	}
+	wg.Wait()
+
+	// Every caller gets the ID the first create was assigned
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated, http.StatusCreated}, codes)
+	assert.Equal(t, []string{"/employees/45", "/employees/45", "/employees/45"}, locations)
+	total, _ := countEmployees(db, EmployeeFilter{})
+	assert.Equal(t, 5, total)
+}
+
+func TestCreateDeduper_PASS_Window_Expires(t *testing.T) {
+	now := time.Now()
+	deduper := newCreateDeduper(time.Second)
+	deduper.now = func() time.Time { return now }
+	var inserts int32
+	create := func() (Employee, error) {
+		return Employee{ID: int(atomic.AddInt32(&inserts, 1))}, nil
+	}
+
+	created, duplicate, err := deduper.do("key", create)
+	assert.False(t, duplicate)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, created.ID)
+	created, duplicate, _ = deduper.do("key", create)
+	assert.True(t, duplicate)
+	assert.Equal(t, 1, created.ID)
+
+	// A different payload is not a duplicate
+	_, duplicate, _ = deduper.do("other", create)
+	assert.False(t, duplicate)
+
+	// Once the window passes the create runs again
+	now = now.Add(time.Second)
+	_, duplicate, _ = deduper.do("key", create)
+	assert.False(t, duplicate)
+	assert.Equal(t, int32(3), inserts)
+}
+
+func TestCreateDeduper_PASS_Failures_Not_Remembered(t *testing.T) {
+	deduper := newCreateDeduper(time.Minute)
+	failure := errors.New("insert failed")
+
+	_, _, err := deduper.do("key", func() (Employee, error) { return Employee{}, failure })
+	assert.Equal(t, failure, err)
+
+	// The retry runs instead of reusing the failure
+	_, duplicate, err := deduper.do("key", func() (Employee, error) { return Employee{}, nil })
+	assert.False(t, duplicate)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Types of employee change events
+const (
+	EventEmployeeCreated = "employee.created"
+	EventEmployeeUpdated = "employee.updated"
+	EventEmployeeDeleted = "employee.deleted"
+)
+
+// How many past events are kept so reconnecting clients can catch up
+const eventHistorySize = 100
+
+// Interval at which a comment is sent to keep idle streams open
+const eventKeepAlive = 15 * time.Second
+
+// EmployeeEvent Struct:
+type EmployeeEvent struct {
+	//Sequence number of the event, sent as the SSE id.
+	Seq int `json:"seq"`
+	//Type of the change, e.g. employee.created.
+	Type string `json:"type"`
+	//ID of the employee that changed.
+	EmployeeID int `json:"employeeId"`
+	//State of the employee after the change, empty on delete.
+	Employee *Employee `json:"employee,omitempty"`
+}
+
+// In-process pub/sub that fans employee events out to subscribers
+type eventBroker struct {
+	mu          sync.Mutex
+	seq         int
+	history     []EmployeeEvent
+	subscribers map[chan EmployeeEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan EmployeeEvent]struct{})}
+}
+
+// Publish the event to every subscriber, dropping it for subscribers that are too slow
+func (b *eventBroker) publish(event EmployeeEvent) EmployeeEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return event
+}
+
+// Subscribe to new events, also returning the missed events after lastSeq
+func (b *eventBroker) subscribe(lastSeq int) (chan EmployeeEvent, []EmployeeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan EmployeeEvent, 16)
+	b.subscribers[ch] = struct{}{}
+
+	var missed []EmployeeEvent
+	if lastSeq > 0 {
+		for _, event := range b.history {
+			if event.Seq > lastSeq {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return ch, missed
+}
+
+func (b *eventBroker) unsubscribe(ch chan EmployeeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// Publish a change event to the stream subscribers and the webhook, if enabled
+func (h *Handler) publish(eventType string, id int, emp *Employee) {
+	event := EmployeeEvent{Type: eventType, EmployeeID: id, Employee: emp}
+	if h.events != nil {
+		event = h.events.publish(event)
+	}
+	if h.webhooks != nil {
+		h.webhooks.notify(event)
+	}
+}
+
+func (h *Handler) employeeStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.events == nil {
+		http.Error(w, "Event stream is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Reconnecting clients send the last id they saw so they can catch up
+	lastSeq, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	events, missed := h.events.subscribe(lastSeq)
+	defer h.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the client how long to wait before reconnecting
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, event := range missed {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			writeEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// Write the event in the text/event-stream format
+func writeEvent(w http.ResponseWriter, event EmployeeEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Read the stream until an event of the given type arrives or the deadline passes
+func readEvent(t *testing.T, reader *bufio.Reader, eventType string) EmployeeEvent {
+	lines := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+
+	var currentType string
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Stream closed before the event was received")
+			}
+			if strings.HasPrefix(line, "event: ") {
+				currentType = strings.TrimPrefix(line, "event: ")
+			}
+			if strings.HasPrefix(line, "data: ") && currentType == eventType {
+				var event EmployeeEvent
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+					t.Fatalf("Error unmarshalling event: %v", err)
+				}
+				return event
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for " + eventType)
+		}
+	}
+}
+
+// Open the stream and wait until the subscription is registered
+func openStream(t *testing.T, ctx context.Context, url string, lastEventID string) *bufio.Reader {
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The retry hint is written once the subscription exists
+	reader := bufio.NewReader(resp.Body)
+	line, _ := reader.ReadString('\n')
+	assert.Equal(t, "retry: 3000\n", line)
+	return reader
+}
+
+func TestEmployeeStreamHandler_PASS_Create_Event(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, events: newEventBroker()}
+	defer handler.db.Close()
+
+	r := chi.NewRouter()
+	r.Get("/employees/stream", handler.employeeStreamHandler)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := openStream(t, ctx, server.URL+"/employees/stream", "")
+
+	// Trigger a create
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Check the event is received
+	event := readEvent(t, reader, EventEmployeeCreated)
+	assert.Equal(t, 1, event.EmployeeID)
+	assert.Equal(t, "John Doe", event.Employee.Name)
+}
+
+func TestEmployeeStreamHandler_PASS_Reconnect_Replays_Missed(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, events: newEventBroker()}
+	defer handler.db.Close()
+
+	r := chi.NewRouter()
+	r.Get("/employees/stream", handler.employeeStreamHandler)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// Events published while the client was disconnected
+	handler.publish(EventEmployeeUpdated, 2, nil)
+	handler.publish(EventEmployeeDeleted, 3, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := openStream(t, ctx, server.URL+"/employees/stream", "1")
+
+	// Only the event after the last seen id is replayed
+	event := readEvent(t, reader, EventEmployeeDeleted)
+	assert.Equal(t, 2, event.Seq)
+	assert.Equal(t, 3, event.EmployeeID)
+}
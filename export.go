@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Header row of the CSV export
+var csvExportHeader = []string{"id", "name", "position", "salary", "department", "hireDate", "managerId"}
+
+func (h *Handler) exportEmployeesCSVHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redact := h.redactSalary(r)
+
+	// call DB layer
+	// Rows are written as they are read, so the export never holds every
+	// employee in memory. The headers wait for the first row so a failing
+	// query can still be answered with a 500.
+	out := csv.NewWriter(w)
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="employees.csv"`)
+		w.WriteHeader(http.StatusOK)
+		out.Write(csvExportHeader)
+	}
+	err = streamEmployeesList(h.dbFor(r), filter, order, -1, 0, func(employee Employee) error {
+		if !started {
+			start()
+		}
+		return out.Write(csvExportRow(employee, redact))
+	})
+	if err != nil && !started {
+		internalError(w, "Error while exporting employees", err)
+		return
+	}
+	if err != nil {
+		// The status is already sent, the truncated file is all the client gets
+		log.Printf("Error while exporting employees: %v", err)
+	}
+
+	// Send Response
+	if !started {
+		start()
+	}
+	out.Flush()
+}
+
+// One CSV row, with the salary left empty when it must be hidden
+func csvExportRow(emp Employee, redactSalary bool) []string {
+	salary, managerID := emp.Salary.String(), ""
+	if redactSalary {
+		salary = ""
+	}
+	if emp.ManagerID != nil {
+		managerID = strconv.Itoa(*emp.ManagerID)
+	}
+	return []string{strconv.Itoa(emp.ID), emp.Name, emp.Position, salary, emp.Department, string(emp.HireDate), managerID}
+}
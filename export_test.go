@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportEmployeesCSVHandler_PASS_Filtered(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Eng' WHERE id IN (2, 3)")
+	db.Exec("UPDATE employees SET department = 'Sales' WHERE id IN (4, 44)")
+
+	// Create a request exporting the well paid engineers
+	req := httptest.NewRequest("GET", "/employees/export.csv?department=Eng&minSalary=50000", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.exportEmployeesCSVHandler(rr, req)
+
+	// Check only the matching row was exported
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,position,salary,department,hireDate,managerId\n"+
+		"2,Alice,Manager,60000.00,Eng,2021-03-15,\n", rr.Body.String())
+}
+
+func TestExportEmployeesCSVHandler_PASS_No_Match(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request exporting a department nobody is in
+	req := httptest.NewRequest("GET", "/employees/export.csv?department=Legal", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.exportEmployeesCSVHandler(rr, req)
+
+	// Check only the header row was exported
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "id,name,position,salary,department,hireDate,managerId\n", rr.Body.String())
+}
+
+func TestExportEmployeesCSVHandler_FAIL_Invalid_Min_Salary(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with a minimum salary that is not an amount
+	req := httptest.NewRequest("GET", "/employees/export.csv?minSalary=lots", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.exportEmployeesCSVHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "minSalary must be an amount")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Newer endpoints grouped by the feature flag enabling them. Each function
+// registers the routes of its feature.
+var featureRoutes = map[string]func(r chi.Router, h *Handler){
+	"bulk": func(r chi.Router, h *Handler) {
+		r.Post("/employees/import.ndjson", h.importEmployeesHandler)
+
+		r.Post("/employees/bulkUpdate", h.bulkUpdateEmployeesHandler)
+
+		r.Get("/employees/import-template.ndjson", h.getImportTemplateHandler)
+	},
+	"export": func(r chi.Router, h *Handler) {
+		r.Get("/employees/{id}.vcf", h.getEmployeeVCardHandler)
+
+		r.Get("/employees/export.csv", h.exportEmployeesCSVHandler)
+
+		r.Get("/employees/feed.atom", h.getEmployeesFeedHandler)
+	},
+	"stats": func(r chi.Router, h *Handler) {
+		r.Get("/departments/salaries", h.getDepartmentSalariesHandler)
+
+		r.Get("/employees/bands", h.getSalaryBandsHandler)
+	},
+}
+
+// Register the routes of every feature. The routes of a disabled feature
+// still exist but answer 404, so they cannot fall through to a broader
+// route such as /employees/{id}.
+func (h *Handler) registerFeatureRoutes(r chi.Router) {
+	for name, register := range featureRoutes {
+		r.Group(func(r chi.Router) {
+			r.Use(h.requireFeature(name))
+			register(r, h)
+		})
+	}
+}
+
+// Whether the feature is enabled. Features are opt-in, none is enabled when FEATURES is not set.
+func (c Config) featureEnabled(name string) bool {
+	return containsString(c.Features, name)
+}
+
+// Answer 404 unless the feature is enabled
+func (h *Handler) requireFeature(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.cfg.featureEnabled(name) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Router with the feature routes next to the broader employee route
+func featureRouter(handler *Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/employees/{id}", handler.getEmployeeByIdHandler)
+	handler.registerFeatureRoutes(r)
+	return r
+}
+
+func TestFeatureRoutes_FAIL_Disabled(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Features: []string{"bulk"}}}
+	defer handler.db.Close()
+
+	for _, path := range []string{"/departments/salaries", "/employees/bands", "/employees/2.vcf"} {
+		// Create a request to a route of a disabled feature
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		featureRouter(&handler).ServeHTTP(rr, req)
+
+		// Check the route does not exist
+		assert.Equal(t, http.StatusNotFound, rr.Code, path)
+	}
+}
+
+func TestFeatureRoutes_PASS_Enabled(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Features: []string{"stats"}}}
+	defer handler.db.Close()
+
+	// Create a request to a route of an enabled feature
+	req := httptest.NewRequest("GET", "/employees/bands", nil)
+	rr := httptest.NewRecorder()
+	featureRouter(&handler).ServeHTTP(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestFeatureRoutes_FAIL_None_Enabled_By_Default(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, path := range []string{"/departments/salaries", "/employees/bands", "/employees/2.vcf"} {
+		// Create a request to a feature route without FEATURES set
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		featureRouter(&handler).ServeHTTP(rr, req)
+
+		// Check the route does not exist until its feature is enabled
+		assert.Equal(t, http.StatusNotFound, rr.Code, path)
+	}
+}
+
+// Every feature, for tests exercising the full router
+func allFeatures() []string {
+	var names []string
+	for name := range featureRoutes {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Atom namespace, the feed element must declare it
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// Newest employees first, those created before timestamps were recorded last
+var feedOrder = EmployeeSort{By: "createdAt", Descending: true}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published,omitempty"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary"`
+}
+
+func (h *Handler) getEmployeesFeedHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, _ = h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getEmployeesPage(h.dbFor(r), EmployeeFilter{}, feedOrder, size, offset, h.cfg.ListTotalWindow)
+	if err != nil {
+		internalError(w, "Error while listing employees", err)
+		return
+	}
+
+	// Send Response
+	feed := h.employeesFeed(employees, size, offset, total)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// Build one page of the feed, linking to the next page when there is one
+func (h *Handler) employeesFeed(employees []Employee, size int, offset int, total int) atomFeed {
+	author := h.cfg.OrgName
+	if author == "" {
+		author = "Employees"
+	}
+	feed := atomFeed{
+		Xmlns:  atomNamespace,
+		ID:     "urn:employees:feed",
+		Title:  author + " employees",
+		Author: atomAuthor{Name: author},
+		Links:  []atomLink{{Rel: "self", Href: feedPageLink(size, offset)}},
+	}
+	if offset+size < total {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: feedPageLink(size, offset+size)})
+	}
+
+	// The feed was last updated when its most recently changed entry was
+	var newest time.Time
+	for _, employee := range employees {
+		employee = h.inTimezone(employee)
+		updated := employee.UpdatedAt.Time
+		if updated.IsZero() {
+			updated = employee.CreatedAt.Time
+		}
+		if updated.After(newest) {
+			newest = updated
+		}
+		entry := atomEntry{
+			ID:      "urn:employees:" + strconv.Itoa(employee.ID),
+			Title:   employee.Name,
+			Updated: updated.Format(time.RFC3339),
+			Link:    atomLink{Rel: "alternate", Href: employeeLocation(employee)},
+			Summary: employee.Position,
+		}
+		if employee.Department != "" {
+			entry.Summary += ", " + employee.Department
+		}
+		if !employee.CreatedAt.IsZero() {
+			entry.Published = employee.CreatedAt.Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = newest.Format(time.RFC3339)
+	return feed
+}
+
+func feedPageLink(size int, offset int) string {
+	return "/employees/feed.atom?offset=" + strconv.Itoa(offset) + "&limit=" + strconv.Itoa(size)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEmployeesFeedHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET created_at = '2024-01-01T00:00:00.000Z' WHERE id = 2")
+	db.Exec("UPDATE employees SET created_at = '2024-03-01T00:00:00.000Z' WHERE id = 3")
+	db.Exec("UPDATE employees SET created_at = '2024-02-01T00:00:00.000Z' WHERE id = 4")
+
+	// Create a request for the first page of the feed
+	req := httptest.NewRequest("GET", "/employees/feed.atom?size=3", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesFeedHandler(rr, req)
+
+	// Check the feed is valid XML with the newest employees first
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	assert.Equal(t, atomNamespace, feed.XMLName.Space)
+	assert.Equal(t, "2024-03-01T00:00:00Z", feed.Updated)
+	var titles []string
+	for _, entry := range feed.Entries {
+		titles = append(titles, entry.Title)
+	}
+	assert.Equal(t, []string{"Jack", "Mary", "Alice"}, titles)
+	assert.Equal(t, "urn:employees:3", feed.Entries[0].ID)
+	assert.Equal(t, "/employees/3", feed.Entries[0].Link.Href)
+	assert.Equal(t, "Writer", feed.Entries[0].Summary)
+
+	// The last employee is on the next page
+	assert.Contains(t, feed.Links, atomLink{Rel: "next", Href: "/employees/feed.atom?offset=3&limit=3"})
+}
+
+func TestGetEmployeesFeedHandler_PASS_Last_Page(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request for a page holding every employee
+	req := httptest.NewRequest("GET", "/employees/feed.atom", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesFeedHandler(rr, req)
+
+	// Check every employee is listed without a next page
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	assert.Equal(t, 4, len(feed.Entries))
+	assert.Equal(t, []atomLink{{Rel: "self", Href: "/employees/feed.atom?offset=0&limit=10"}}, feed.Links)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ID strategies selectable with ID_STRATEGY
+const (
+	// The server hands out IDs from a counter that only grows
+	idStrategySequence = "sequence"
+	// Employees get a random UUID used in paths, the DB assigns the integer ID
+	idStrategyUUID = "uuid"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Monotonic generator of employee IDs. Generated IDs are unique across
+// concurrent creates and never reused while the server runs, and IDs chosen
+// by clients are skipped. A nil generator lets the DB assign IDs.
+type idGenerator struct {
+	mu   sync.Mutex
+	last int
+	// Give each employee a UUID and leave the integer ID to the DB
+	uuids bool
+}
+
+func newIDGenerator() *idGenerator {
+	return &idGenerator{}
+}
+
+func newUUIDGenerator() *idGenerator {
+	return &idGenerator{uuids: true}
+}
+
+// Insert the employee under a newly generated ID and return it as inserted
+func (g *idGenerator) create(db querier, emp Employee) (Employee, error) {
+	var err error
+	if g == nil {
+		emp.ID, err = createEmployeeWithGeneratedID(db, emp)
+		return emp, err
+	}
+	if g.uuids {
+		if emp.UUID == "" {
+			emp.UUID = newUUID()
+		}
+		emp.ID, err = createEmployeeWithGeneratedID(db, emp)
+		return emp, err
+	}
+
+	// Held until the insert is done so no other create can take the ID
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var max int
+	if err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM employees").Scan(&max); err != nil {
+		return emp, err
+	}
+	if max > g.last {
+		g.last = max
+	}
+	emp.ID = g.last + 1
+	if err := createEmployee(db, emp); err != nil {
+		return emp, err
+	}
+	g.last = emp.ID
+	return emp, nil
+}
+
+// Random version 4 UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
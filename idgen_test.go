@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDGenerator_PASS_Unique_Under_Concurrency(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	ids := newIDGenerator()
+
+	// Clone the same employee from many goroutines at once
+	const clones = 50
+	generated := make(chan int, clones)
+	var wg sync.WaitGroup
+	for i := 0; i < clones; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clone, err := cloneEmployee(db, ids, 2)
+			assert.Nil(t, err)
+			generated <- clone.ID
+		}()
+	}
+	wg.Wait()
+	close(generated)
+
+	// Check every clone got its own ID above the existing ones
+	seen := make(map[int]bool)
+	for id := range generated {
+		assert.False(t, seen[id], "ID %d generated twice", id)
+		assert.Greater(t, id, 44)
+		seen[id] = true
+	}
+	assert.Equal(t, clones, len(seen))
+}
+
+func TestIDGenerator_PASS_Never_Reuses_IDs(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	ids := newIDGenerator()
+
+	first, err := ids.create(db, Employee{Name: "Bob", Position: "Engineer", Salary: 50000_00})
+	assert.Nil(t, err)
+	assert.Equal(t, 45, first.ID)
+
+	// Deleting the newest employee does not free its ID
+	_, err = deleteEmployee(db, first.ID, false)
+	assert.Nil(t, err)
+	second, _ := ids.create(db, Employee{Name: "Bob", Position: "Engineer", Salary: 50000_00})
+	assert.Equal(t, 46, second.ID)
+
+	// IDs picked by clients are skipped
+	createEmployee(db, Employee{ID: 60, Name: "Eve", Position: "Analyst", Salary: 50000_00})
+	third, _ := ids.create(db, Employee{Name: "Bob", Position: "Engineer", Salary: 50000_00})
+	assert.Equal(t, 61, third.ID)
+}
+
+func TestCreateEmployeeHandler_PASS_UUID_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{IDStrategy: idStrategyUUID}, ids: newUUIDGenerator()}
+	defer handler.db.Close()
+
+	// Create an employee without an ID
+	reqBody := []byte(`{"name":"Bob","position":"Engineer","salary":50000}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// The location names the employee by UUID
+	location := rr.Header().Get("Location")
+	uuid := strings.TrimPrefix(location, "/employees/")
+	assert.Regexp(t, uuidPattern, uuid)
+
+	// Fetch the employee by its UUID
+	req = httptest.NewRequest("GET", location, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", uuid)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var employee Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &employee); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, uuid, employee.UUID)
+	assert.Equal(t, "Bob", employee.Name)
+	assert.NotEqual(t, 0, employee.ID)
+}
+
+func TestCreateEmployeeHandler_PASS_Sequence_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := newHandler(Config{IDStrategy: idStrategySequence}, db)
+	defer handler.db.Close()
+
+	for _, expected := range []int{45, 46} {
+		// Create an employee without an ID
+		reqBody := []byte(`{"name":"Bob","position":"Engineer","salary":50000}`)
+		req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+		rr := httptest.NewRecorder()
+		handler.createEmployeeHandler(rr, req)
+
+		// Check the next ID of the sequence was assigned
+		assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `"id":`+strconv.Itoa(expected))
+		assert.Equal(t, "/employees/"+strconv.Itoa(expected), rr.Header().Get("Location"))
+	}
+
+	// An explicit ID is still inserted as given
+	reqBody := []byte(`{"id":100,"name":"Eve","position":"Engineer","salary":50000}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	_, err := getEmployeeById(db, 100)
+	assert.Nil(t, err)
+}
+
+func TestCreateEmployeeHandler_FAIL_No_ID_Without_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := newHandler(Config{}, db)
+	defer handler.db.Close()
+
+	// Without a server-side strategy the client must choose the ID
+	reqBody := []byte(`{"name":"Bob","position":"Engineer","salary":50000}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Employee ID cannot be 0")
+}
+
+func TestUpsertEmployeeHandler_FAIL_No_ID_With_UUID_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := newHandler(Config{IDStrategy: idStrategyUUID}, db)
+	defer handler.db.Close()
+
+	// An upsert needs an ID to match, even when creates can go without one
+	reqBody := []byte(`{"name":"Bob","position":"Engineer","salary":50000}`)
+	req := httptest.NewRequest("POST", "/upsertEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.upsertEmployeeHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	_, err := getEmployeeById(db, 0)
+	assert.True(t, errors.Is(err, ErrEmployeeNotFound))
+}
+
+func TestImportEmployeesHandler_PASS_Sequence_Strategy(t *testing.T) {
+	db := setupDatabase()
+	handler := newHandler(Config{IDStrategy: idStrategySequence}, db)
+	defer handler.db.Close()
+
+	// Import lines without an ID next to one with an ID
+	body := `{"name":"Bob","position":"Engineer","salary":50000}
+{"id":100,"name":"Eve","position":"Analyst","salary":45000}
+{"name":"Dan","position":"Designer","salary":40000}
+`
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	// Check the lines without an ID got the next IDs of the sequence
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"imported":3,"failed":0}`+"\n", rr.Body.String())
+	for id, name := range map[int]string{45: "Bob", 100: "Eve", 101: "Dan"} {
+		employee, err := getEmployeeById(db, id)
+		assert.Nil(t, err)
+		assert.Equal(t, name, employee.Name)
+	}
+}
+
+func TestGetEmployeeHandler_FAIL_Unknown_UUID(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{IDStrategy: idStrategyUUID}, ids: newUUIDGenerator()}
+	defer handler.db.Close()
+	// An unknown UUID must not resolve to a row with ID 0
+	createEmployee(db, Employee{ID: 0, Name: "Zero", Position: "Engineer", Salary: 50000_00})
+
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", newUUID())
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	handler.getEmployeeByIdHandler(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestCloneEmployee_PASS_UUID_Strategy(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	ids := newUUIDGenerator()
+
+	// Clones get their own UUID
+	first, err := cloneEmployee(db, ids, 2)
+	assert.Nil(t, err)
+	second, err := cloneEmployee(db, ids, first.ID)
+	assert.Nil(t, err)
+	assert.Regexp(t, uuidPattern, first.UUID)
+	assert.Regexp(t, uuidPattern, second.UUID)
+	assert.NotEqual(t, first.UUID, second.UUID)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Rows inserted per transaction by an import
+const importBatchSize = 500
+
+// Longest line accepted in an import
+const importMaxLineBytes = 1 << 20
+
+// ImportResult Struct:
+// Outcome of an NDJSON import.
+type ImportResult struct {
+	//Number of employees inserted.
+	Imported int `json:"imported"`
+	//Number of lines rejected.
+	Failed int `json:"failed"`
+	//The first rejected line, absent when every line was imported.
+	FirstError *ImportError `json:"firstError,omitempty"`
+}
+
+// ImportError Struct:
+type ImportError struct {
+	//Line number, starting at 1.
+	Line int `json:"line"`
+	//Why the line was rejected.
+	Error string `json:"error"`
+}
+
+// Employee fields set by the server, which an import ignores
+var importIgnoredFields = map[string]bool{"version": true, "createdAt": true, "updatedAt": true, "uuid": true}
+
+// Keys of the import template, the JSON names of the Employee fields an
+// import sets, read from the struct so the template follows new fields
+func importTemplateHeader() []string {
+	var header []string
+	for _, field := range importTemplateFields() {
+		header = append(header, importFieldName(field))
+	}
+	return header
+}
+
+// The Employee fields an import sets, in struct order
+func importTemplateFields() []reflect.StructField {
+	var fields []reflect.StructField
+	employee := reflect.TypeOf(Employee{})
+	for i := 0; i < employee.NumField(); i++ {
+		name := importFieldName(employee.Field(i))
+		if name != "" && name != "-" && !importIgnoredFields[name] {
+			fields = append(fields, employee.Field(i))
+		}
+	}
+	return fields
+}
+
+func importFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// One line of POST /employees/import.ndjson with every key an import reads,
+// each set to its zero value
+func importTemplateLine() ([]byte, error) {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, field := range importTemplateFields() {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		value, err := json.Marshal(reflect.Zero(field.Type).Interface())
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&line, "%q:%s", importFieldName(field), value)
+	}
+	line.WriteString("}\n")
+	return line.Bytes(), nil
+}
+
+// Returned when an import has more employees than a request may carry
+var ErrImportTooLarge = errors.New("too many employees in one import")
+
+// Insert the employees read one per line from r, batchSize per transaction.
+// Lines without an ID get one from ids. Lines that are invalid or fail to
+// insert are counted and skipped. created is called with the employees of
+// each committed batch and the result so far.
+// A line past maxItems stops the import with ErrImportTooLarge, 0 for no limit.
+// Once ctx is done the import stops before starting another batch and returns
+// ctx.Err(), so only whole batches are ever committed.
+func importEmployees(ctx context.Context, db database, ids *idGenerator, r io.Reader, batchSize int, maxItems int, validate func(Employee) error,
+	created func(batch []Employee, sofar ImportResult)) (ImportResult, error) {
+	var result ImportResult
+	reject := func(line int, err error) {
+		result.Failed++
+		if result.FirstError == nil {
+			result.FirstError = &ImportError{Line: line, Error: err.Error()}
+		}
+	}
+
+	var tx transaction
+	var batch []Employee
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		result.Imported += len(batch)
+		created(batch, result)
+		tx, batch = nil, nil
+		return nil
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), importMaxLineBytes)
+	line, items := 0, 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if items++; maxItems > 0 && items > maxItems {
+			return result, ErrImportTooLarge
+		}
+		var employee Employee
+		if err := json.Unmarshal(scanner.Bytes(), &employee); err != nil {
+			reject(line, err)
+			continue
+		}
+		if err := validate(employee); err != nil {
+			reject(line, err)
+			continue
+		}
+
+		if tx == nil {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			var err error
+			if tx, err = begin(db); err != nil {
+				return result, err
+			}
+		}
+		// A failed insert only undoes its own statement, the batch carries on
+		var err error
+		if employee.ID == 0 {
+			employee, err = ids.create(tx, employee)
+		} else {
+			err = createEmployee(tx, employee)
+		}
+		if err != nil {
+			reject(line, err)
+			continue
+		}
+		batch = append(batch, employee)
+		if len(batch) >= batchSize {
+			if err := commit(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	return result, commit()
+}
+
+func (h *Handler) importEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	// With progress the response is NDJSON: a progress line after each batch
+	// and the result, or an error, on the last line
+	var progress *importProgress
+	if r.URL.Query().Get("progress") == "true" {
+		progress = newImportProgress(w)
+	}
+
+	// call DB layer
+	result, err := importEmployees(r.Context(), h.dbFor(r), h.ids, r.Body, importBatchSize, h.cfg.MaxBatchItems, func(emp Employee) error {
+		return validateEmployee(emp, h.cfg)
+	}, func(batch []Employee, sofar ImportResult) {
+		for i := range batch {
+			h.publish(EventEmployeeCreated, batch[i].ID, &batch[i])
+		}
+		progress.write(map[string]int{"processed": sofar.Imported + sofar.Failed})
+	})
+	if errors.Is(err, bufio.ErrTooLong) || errors.Is(err, ErrImportTooLarge) {
+		message := "A line is longer than " + strconv.Itoa(importMaxLineBytes) + " bytes, imported " +
+			strconv.Itoa(result.Imported) + " employees before it"
+		if errors.Is(err, ErrImportTooLarge) {
+			message = "An import cannot have more than " + strconv.Itoa(h.cfg.MaxBatchItems) +
+				" employees, imported " + strconv.Itoa(result.Imported) + " employees before the limit"
+		}
+		if progress != nil {
+			progress.write(map[string]string{"error": message})
+			return
+		}
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		message := "Import was interrupted, imported " + strconv.Itoa(result.Imported) + " employees before it stopped"
+		if progress != nil {
+			progress.write(map[string]string{"error": message})
+			return
+		}
+		http.Error(w, message, http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		message := "Error while importing employees after " + strconv.Itoa(result.Imported) + " employees"
+		if progress != nil {
+			// The status is already sent, so the reference goes in the last line
+			ref := newErrorRef()
+			log.Printf("%s (ref %s): %v", message, ref, err)
+			progress.write(map[string]string{"error": "internal error, ref: " + ref})
+			return
+		}
+		internalError(w, message, err)
+		return
+	}
+
+	// Send Response
+	if progress != nil {
+		progress.write(result)
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, result)
+}
+
+// Answer with a template of the NDJSON import, in the format the importer
+// reads
+func (h *Handler) getImportTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	line, err := importTemplateLine()
+	if err != nil {
+		internalError(w, "Error while building the import template", err)
+		return
+	}
+
+	// Send Response
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="employees-import-template.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(line); err != nil {
+		log.Printf("Error while writing the import template: %v", err)
+	}
+}
+
+// Writes the lines of a streamed import, flushing each so the client sees
+// progress while the import runs
+type importProgress struct {
+	flusher http.Flusher
+	encoder *json.Encoder
+}
+
+func newImportProgress(w http.ResponseWriter) *importProgress {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	return &importProgress{flusher: flusher, encoder: json.NewEncoder(w)}
+}
+
+// Write one line, doing nothing when progress was not requested
+func (p *importProgress) write(v interface{}) {
+	if p == nil {
+		return
+	}
+	p.encoder.Encode(v)
+	if p.flusher != nil {
+		p.flusher.Flush()
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportEmployeesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with one employee per line
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"Eve","position":"Analyst","salary":45000}
+
+{"id":12,"name":"Dan","position":"Designer","salary":40000}
+`
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	var result ImportResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check every line was imported
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, ImportResult{Imported: 3}, result)
+	for _, id := range []int{10, 11, 12} {
+		_, err := getEmployeeById(db, id)
+		assert.Nil(t, err)
+	}
+}
+
+func TestImportEmployeesHandler_PASS_Invalid_Line(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// The second line has no name, the fourth is not JSON
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"","position":"Analyst","salary":45000}
+{"id":12,"name":"Dan","position":"Designer","salary":40000}
+{"id":13,
+`
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	var result ImportResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the valid lines were imported and the first bad one reported
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, &ImportError{Line: 2, Error: "Employee Name cannot be blank"}, result.FirstError)
+	_, err := getEmployeeById(db, 11)
+	assert.True(t, errors.Is(err, ErrEmployeeNotFound))
+}
+
+func TestImportEmployees_PASS_Batches(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	// Line 3 clashes with an existing ID, the rest of its batch still commits
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"Eve","position":"Analyst","salary":45000}
+{"id":2,"name":"Alice","position":"Manager","salary":60000}
+{"id":12,"name":"Dan","position":"Designer","salary":40000}
+{"id":13,"name":"Ann","position":"Designer","salary":40000}
+`
+	var batches []int
+	result, err := importEmployees(context.Background(), db, nil, strings.NewReader(body), 2, 0, func(Employee) error { return nil },
+		func(batch []Employee, sofar ImportResult) { batches = append(batches, len(batch)) })
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, result.Imported)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 3, result.FirstError.Line)
+	assert.Equal(t, []int{2, 2}, batches)
+}
+
+func TestImportEmployees_PASS_Cancelled_Between_Batches(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	// Cancel once the first batch is committed, as a shutdown would
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"Eve","position":"Analyst","salary":45000}
+{"id":12,"name":"Dan","position":"Designer","salary":40000}
+{"id":13,"name":"Ann","position":"Designer","salary":40000}
+`
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result, err := importEmployees(ctx, db, nil, strings.NewReader(body), 2, 0, func(Employee) error { return nil },
+		func(batch []Employee, sofar ImportResult) { cancel() })
+
+	// Check the first batch is committed in full and the second never started
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 2, result.Imported)
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM employees WHERE id BETWEEN 10 AND 13").Scan(&count)
+	assert.Equal(t, 2, count)
+	_, err = getEmployeeById(db, 11)
+	assert.Nil(t, err)
+	_, err = getEmployeeById(db, 12)
+	assert.ErrorIs(t, err, ErrEmployeeNotFound)
+}
+
+func TestImportEmployeesHandler_FAIL_Interrupted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request whose context is already done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}` + "\n"
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	// Check nothing was imported
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Import was interrupted, imported 0 employees")
+	_, err := getEmployeeById(db, 10)
+	assert.ErrorIs(t, err, ErrEmployeeNotFound)
+}
+
+func TestImportEmployeesHandler_PASS_Progress(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with more than two batches of employees
+	var body strings.Builder
+	for id := 100; id < 100+2*importBatchSize+10; id++ {
+		fmt.Fprintf(&body, `{"id":%d,"name":"Bob","position":"Engineer","salary":50000}`+"\n", id)
+	}
+	req := httptest.NewRequest("POST", "/employees/import.ndjson?progress=true", strings.NewReader(body.String()))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	// Check a progress line was sent per batch, before the result
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.True(t, rr.Flushed)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.Equal(t, []string{
+		`{"processed":500}`,
+		`{"processed":1000}`,
+		`{"processed":1010}`,
+		`{"imported":1010,"failed":0}`,
+	}, lines)
+}
+
+func TestImportEmployeesHandler_PASS_At_Limit(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{MaxBatchItems: 2}}
+	defer handler.db.Close()
+
+	// Create a request with as many employees as allowed
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"Eve","position":"Analyst","salary":45000}
+`
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	// Check every line was imported
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"imported":2,"failed":0}`, rr.Body.String())
+}
+
+func TestImportEmployeesHandler_FAIL_Over_Limit(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{MaxBatchItems: 2}}
+	defer handler.db.Close()
+
+	// Create a request with one employee more than allowed
+	body := `{"id":10,"name":"Bob","position":"Engineer","salary":50000}
+{"id":11,"name":"Eve","position":"Analyst","salary":45000}
+{"id":12,"name":"Dan","position":"Designer","salary":40000}
+`
+	req := httptest.NewRequest("POST", "/employees/import.ndjson", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.importEmployeesHandler(rr, req)
+
+	// Check the import was stopped before the extra line
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "An import cannot have more than 2 employees")
+	_, err := getEmployeeById(db, 12)
+	assert.True(t, errors.Is(err, ErrEmployeeNotFound))
+}
+
+func TestGetImportTemplateHandler_PASS(t *testing.T) {
+	handler := Handler{}
+
+	// Create a request for the template
+	req := httptest.NewRequest("GET", "/employees/import-template.ndjson", nil)
+	rr := httptest.NewRecorder()
+	handler.getImportTemplateHandler(rr, req)
+
+	// Check the template is one import line with the current fields in order
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `{"id":0,"name":"","position":"","salary":0.00,"department":"","hireDate":"","managerId":null}`+"\n",
+		rr.Body.String())
+
+	// The importer reads the template line, only rejecting its empty values
+	db := setupDatabase()
+	defer db.Close()
+	result, err := importEmployees(context.Background(), db, nil, bytes.NewReader(rr.Body.Bytes()), 1, 0,
+		func(emp Employee) error { return validateEmployee(emp, Config{}) }, func([]Employee, ImportResult) {})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, "Employee ID cannot be 0", result.FirstError.Error)
+
+	// Every JSON field of an employee is either a column or set by the server
+	employee, _ := json.Marshal(Employee{UUID: "x"})
+	var fields map[string]interface{}
+	json.Unmarshal(employee, &fields)
+	header := importTemplateHeader()
+	for field := range fields {
+		assert.True(t, importIgnoredFields[field] != containsString(header, field), field)
+	}
+	assert.Equal(t, len(fields), len(header)+len(importIgnoredFields))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version reported by GET /, set at build time with
+// -ldflags "-X main.apiVersion=1.2.0"
+var apiVersion = "dev"
+
+// API name reported by GET / when none is configured
+const defaultAPIName = "Employees API"
+
+type apiIndex struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Endpoints []string `json:"endpoints"`
+}
+
+// Landing payload of the API, listing the endpoints the router serves as
+// "METHOD /pattern". Routes answering 404 in this configuration, those of
+// disabled features and the test-only ones, are left out.
+func (h *Handler) indexHandler(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hidden := h.hiddenRoutes()
+		index := apiIndex{Name: h.cfg.apiName(), Version: apiVersion, Endpoints: []string{}}
+		err := chi.Walk(routes, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			endpoint := method + " " + route
+			if !hidden[endpoint] {
+				index.Endpoints = append(index.Endpoints, endpoint)
+			}
+			return nil
+		})
+		if err != nil {
+			internalError(w, "Error while listing endpoints", err)
+			return
+		}
+		sort.Slice(index.Endpoints, func(i, j int) bool {
+			return endpointLess(index.Endpoints[i], index.Endpoints[j])
+		})
+
+		// Send Response
+		h.writeJSON(w, r, http.StatusOK, index)
+	}
+}
+
+// The "METHOD /pattern" of every route registered but answering 404
+func (h *Handler) hiddenRoutes() map[string]bool {
+	disabled := chi.NewRouter()
+	for name, register := range featureRoutes {
+		if !h.cfg.featureEnabled(name) {
+			register(disabled, h)
+		}
+	}
+	if !h.cfg.TestMode {
+		disabled.Post("/test/reset", h.resetDatabaseHandler)
+	}
+	hidden := make(map[string]bool)
+	chi.Walk(disabled, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		hidden[method+" "+route] = true
+		return nil
+	})
+	return hidden
+}
+
+// Order endpoints by path, then method
+func endpointLess(a string, b string) bool {
+	methodA, pathA, _ := strings.Cut(a, " ")
+	methodB, pathB, _ := strings.Cut(b, " ")
+	if pathA != pathB {
+		return pathA < pathB
+	}
+	return methodA < methodB
+}
+
+func (c Config) apiName() string {
+	if c.APIName == "" {
+		return defaultAPIName
+	}
+	return c.APIName
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	router := newRouter(newHandler(Config{APIName: "Acme Staff", TestMode: true, Features: allFeatures()}, db))
+
+	// Create a request for the root of the API
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the router
+	router.ServeHTTP(rr, req)
+
+	// Check the metadata lists the endpoints the router serves
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var index apiIndex
+	if err := json.Unmarshal(rr.Body.Bytes(), &index); err != nil {
+		t.Fatalf("index is not valid JSON: %v", err)
+	}
+	assert.Equal(t, "Acme Staff", index.Name)
+	assert.Equal(t, apiVersion, index.Version)
+	assert.Contains(t, index.Endpoints, "GET /")
+	assert.Contains(t, index.Endpoints, "GET /employees/{id}")
+	assert.Contains(t, index.Endpoints, "DELETE /deleteEmployee/{id}")
+	assert.Contains(t, index.Endpoints, "POST /admin/recomputeSalaries")
+	assert.Contains(t, index.Endpoints, "GET /employees/feed.atom")
+	assert.Contains(t, index.Endpoints, "POST /test/reset")
+	assert.Less(t, indexOf(index.Endpoints, "POST /createEmployee"), indexOf(index.Endpoints, "GET /employees/{id}"))
+}
+
+func TestIndexHandler_PASS_Hides_Disabled_Routes(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	router := newRouter(newHandler(Config{Features: []string{"stats"}}, db))
+
+	// Create a request for the root of the API
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	// Check routes answering 404 in this configuration are not listed
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var index apiIndex
+	json.Unmarshal(rr.Body.Bytes(), &index)
+	assert.Equal(t, defaultAPIName, index.Name)
+	assert.Contains(t, index.Endpoints, "GET /employees/bands")
+	assert.NotContains(t, index.Endpoints, "GET /employees/feed.atom")
+	assert.NotContains(t, index.Endpoints, "POST /employees/bulkUpdate")
+	assert.NotContains(t, index.Endpoints, "POST /test/reset")
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
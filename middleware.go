@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Reject requests with a 500 when the handler has no database instead of
+// panicking inside the DB layer
+func (h *Handler) requireDB(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.db == nil {
+			http.Error(w, "Internal error: database not configured", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reject requests whose query string is longer than maxBytes with a 414,
+// before any handler spends time parsing it. 0 disables the limit.
+func limitQueryLength(maxBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes > 0 && len(r.URL.RawQuery) > maxBytes {
+				http.Error(w, "Query string is too long", http.StatusRequestURITooLong)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Query params only ever holding plain text, checked by rejectSQLLikeParams
+var plainQueryParams = []string{"search", "name", "department", "tag"}
+
+// Plain text params matched against employee names, where an apostrophe is
+// legitimate, as in O'Brien
+var nameQueryParams = []string{"search", "name"}
+
+// Sequences that have no place in plain text but are common in SQL injection attempts
+var sqlMetaSequences = []string{"'", "\"", ";", "--", "/*", "*/", "\x00"}
+
+// Reject with a 400 requests whose plain text query params contain SQL
+// meta-characters. Queries are parameterized so these values are harmless,
+// this only stops obvious probing early. Off unless enabled.
+func rejectSQLLikeParams(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			for _, param := range plainQueryParams {
+				for _, value := range query[param] {
+					if containsSQLMeta(param, value) {
+						log.Printf("Rejected suspicious %s parameter from %s: %q", param, r.RemoteAddr, value)
+						http.Error(w, param+" contains characters that are not allowed", http.StatusBadRequest)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsSQLMeta(param string, value string) bool {
+	for _, sequence := range sqlMetaSequences {
+		if sequence == "'" && containsString(nameQueryParams, param) {
+			continue
+		}
+		if strings.Contains(value, sequence) {
+			return true
+		}
+	}
+	return false
+}
+
+// Answer 406 to requests whose Accept-Charset rules out UTF-8, the only
+// charset responses are written in, and label every response as UTF-8
+func utf8Only(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsUTF8(r.Header.Get("Accept-Charset")) {
+			http.Error(w, "Responses are only available in utf-8", http.StatusNotAcceptable)
+			return
+		}
+		next.ServeHTTP(&charsetResponseWriter{ResponseWriter: w}, r)
+	})
+}
+
+// Whether an Accept-Charset header such as "iso-8859-1, utf-8;q=0.5" allows
+// UTF-8. An explicit utf-8 entry wins over a * wildcard.
+func acceptsUTF8(header string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+	wildcard := false
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch name {
+		case "utf-8", "utf8":
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// Adds charset=utf-8 to the Content-Type of the response when it has none
+type charsetResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (c *charsetResponseWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		header := c.Header()
+		if contentType := header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "charset=") {
+			header.Set("Content-Type", contentType+"; charset=utf-8")
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *charsetResponseWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *charsetResponseWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Put a deadline on each request, looked up by its route pattern, e.g.
+// "/employees/{id}", falling back to def. A timeout of 0 means none. The DB
+// calls of a handler run with the request context, so they stop at the
+// deadline and the handler answers with a 503. Streaming responses, the SSE
+// stream, ?stream=true lists and ?progress=true imports, are never cut short.
+func routeTimeouts(def time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := matchRoutePattern(r)
+			timeout, ok := routes[pattern]
+			if !ok {
+				timeout = def
+			}
+			if timeout <= 0 || isStreamingRequest(r, pattern) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Whether the request is answered as a stream for as long as it runs
+func isStreamingRequest(r *http.Request, pattern string) bool {
+	query := r.URL.Query()
+	return pattern == "/employees/stream" || query.Get("stream") == "true" || query.Get("progress") == "true"
+}
+
+// Pattern of the route the router will pick for the request. Middleware runs
+// before routing, so the pattern is found with a separate match.
+func matchRoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	match := chi.NewRouteContext()
+	if !rctx.Routes.Match(match, r.Method, r.URL.Path) {
+		return ""
+	}
+	return match.RoutePattern()
+}
+
+// Gzip responses of at least minBytes for clients that accept it. Smaller
+// responses are sent as is, since compressing them costs more than it saves.
+func gzipResponses(minBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w, minBytes: minBytes}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// Whether an Accept-Encoding header such as "br, gzip;q=0.5" allows gzip.
+// An explicit gzip entry wins over a * wildcard, and q=0 refuses it.
+func acceptsGzip(header string) bool {
+	wildcard := false
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch name {
+		case "gzip", "x-gzip":
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// Buffers the response until it knows whether it is large enough to compress
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minBytes int
+	status   int
+	buf      bytes.Buffer
+	gz       *gzip.Writer
+	started  bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.started {
+		g.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.started {
+		if g.gz != nil {
+			return g.gz.Write(p)
+		}
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf.Write(p)
+	if g.buf.Len() >= g.minBytes {
+		if err := g.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flushing commits to a decision with what has been buffered so far, so
+// streaming responses are not held back
+func (g *gzipResponseWriter) Flush() {
+	if !g.started {
+		g.start(g.buf.Len() >= g.minBytes)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Send the headers and the buffered body, compressed or not
+func (g *gzipResponseWriter) start(compress bool) error {
+	g.started = true
+	header := g.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		header.Add("Vary", "Accept-Encoding")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+
+	data := g.buf.Bytes()
+	g.buf = bytes.Buffer{}
+	if len(data) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(data)
+	} else {
+		_, err = g.ResponseWriter.Write(data)
+	}
+	return err
+}
+
+func (g *gzipResponseWriter) close() {
+	if !g.started {
+		g.start(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireDB_FAIL_Nil_DB(t *testing.T) {
+	handler := Handler{db: nil}
+
+	// Create a request to get an employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function behind the same middleware as the router
+	h := middleware.Recoverer(handler.requireDB(http.HandlerFunc(handler.getEmployeeByIdHandler)))
+	assert.NotPanics(t, func() { h.ServeHTTP(rr, req) })
+
+	// Check the status code
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "database not configured")
+}
+
+func TestGzipResponses_PASS_Small_Response_Uncompressed(t *testing.T) {
+	body := `{"id":2}`
+	h := gzipResponses(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest("GET", "/employees/2", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// Check the body is sent as is
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rr.Body.String())
+}
+
+func TestGzipResponses_PASS_Refused_Encoding_Uncompressed(t *testing.T) {
+	body := strings.Repeat(`{"id":2,"name":"Alice"},`, 100)
+	h := gzipResponses(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+
+	for _, header := range []string{"", "gzip;q=0", "identity", "*;q=0", "*, gzip;q=0"} {
+		req := httptest.NewRequest("GET", "/getEmployees", nil)
+		req.Header.Set("Accept-Encoding", header)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		// Check the body is sent as is
+		assert.Equal(t, "", rr.Header().Get("Content-Encoding"), header)
+		assert.Equal(t, body, rr.Body.String(), header)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"gzip":            true,
+		"GZIP":            true,
+		"br, gzip;q=0.5":  true,
+		"x-gzip":          true,
+		"*":               true,
+		"gzip;q=0":        false,
+		"gzip;q=0.0, *":   false,
+		"deflate":         false,
+		"identity, *;q=0": false,
+		"":                false,
+	}
+	for header, expected := range tests {
+		assert.Equal(t, expected, acceptsGzip(header), header)
+	}
+}
+
+func TestGzipResponses_PASS_Large_Response_Compressed(t *testing.T) {
+	body := strings.Repeat(`{"id":2,"name":"Alice"},`, 100)
+	h := gzipResponses(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		// Written in small pieces so the threshold is crossed part way
+		for i := 0; i < len(body); i += 100 {
+			end := i + 100
+			if end > len(body) {
+				end = len(body)
+			}
+			w.Write([]byte(body[i:end]))
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/getEmployees", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// Check the body is compressed and decompresses to the original
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rr.Body)
+	assert.Nil(t, err)
+	decompressed, _ := io.ReadAll(reader)
+	assert.Equal(t, body, string(decompressed))
+}
+
+func TestLimitQueryLength_FAIL_Too_Long(t *testing.T) {
+	h := limitQueryLength(2048)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler must not run for an over-long query")
+	}))
+
+	req := httptest.NewRequest("GET", "/getEmployees?"+strings.Repeat("tag=remote&", 200), nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// Check the request is rejected
+	assert.Equal(t, http.StatusRequestURITooLong, rr.Code)
+}
+
+func TestLimitQueryLength_PASS_Within_Limit(t *testing.T) {
+	h := limitQueryLength(2048)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/getEmployees?page=2&size=10&tag=remote", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// Check the request reaches the handler
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRejectSQLLikeParams_FAIL_Flagged(t *testing.T) {
+	h := rejectSQLLikeParams(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler must not run for a suspicious query")
+	}))
+
+	for _, query := range []string{"search=x%27%20OR%201%3D1--", "department=Sales%3BDROP%20TABLE%20employees",
+		"tag=remote&tag=a/*b*/", "department=O%27Brien", "name=O%27Brien%27--"} {
+		req := httptest.NewRequest("GET", "/getEmployees?"+query, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		// Check the request is rejected
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.Contains(t, rr.Body.String(), "contains characters that are not allowed", query)
+	}
+}
+
+func TestRejectSQLLikeParams_PASS_Benign(t *testing.T) {
+	h := rejectSQLLikeParams(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Only the plain text params are checked, and names may hold an apostrophe
+	for _, path := range []string{"/getEmployees?search=mary-jane&department=R%26D&sortBy=name%27",
+		"/getEmployees?search=O%27Brien", "/searchEmployees?name=O%27Brien"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		// Check the request reaches the handler
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+	}
+}
+
+func TestRejectSQLLikeParams_PASS_Disabled(t *testing.T) {
+	h := rejectSQLLikeParams(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/getEmployees?search=O%27Brien", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	// Check nothing is rejected by default
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestUTF8Only_FAIL_Unsupported_Charset(t *testing.T) {
+	h := utf8Only(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler must not run for an unsupported charset")
+	}))
+
+	for _, charset := range []string{"iso-8859-1", "utf-16, iso-8859-1;q=0.5", "*, utf-8;q=0"} {
+		req := httptest.NewRequest("GET", "/employees/2", nil)
+		req.Header.Set("Accept-Charset", charset)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		// Check the request is rejected
+		assert.Equal(t, http.StatusNotAcceptable, rr.Code, charset)
+	}
+}
+
+func TestUTF8Only_PASS_Charset_In_Content_Type(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	h := utf8Only(http.HandlerFunc(handler.getDepartmentSalariesHandler))
+
+	for _, charset := range []string{"", "utf-8", "iso-8859-1, UTF-8;q=0.5", "*"} {
+		req := httptest.NewRequest("GET", "/departments/salaries", nil)
+		req.Header.Set("Accept-Charset", charset)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		// Check the response is labelled as UTF-8
+		assert.Equal(t, http.StatusOK, rr.Code, charset)
+		assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"), charset)
+	}
+}
+
+func TestRouteTimeouts_PASS_Per_Route(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(routeTimeouts(0, map[string]time.Duration{
+		"/employees/{id}": 10 * time.Millisecond,
+		"/getEmployees":   time.Second,
+	}))
+	// Both routes take the same time to answer
+	slowHandler := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			internalError(w, "Slow request", r.Context().Err())
+		}
+	}
+	r.Get("/employees/{id}", slowHandler)
+	r.Get("/getEmployees", slowHandler)
+
+	// Check the point read times out while the list is given longer
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/employees/2", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/getEmployees", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRouteTimeouts_PASS_Default(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(routeTimeouts(10*time.Millisecond, map[string]time.Duration{"/getEmployees": 0}))
+	r.Get("/employees/{id}", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		internalError(w, "Slow request", r.Context().Err())
+	})
+	r.Get("/getEmployees", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Routes not listed use the default
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/employees/2", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	// A timeout of 0 disables it for the route
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/getEmployees", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRouteTimeouts_PASS_Deadline_Reaches_Queries(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	router := newRouter(newHandler(Config{RouteTimeouts: map[string]time.Duration{"/employees/{id}": time.Nanosecond}}, db))
+
+	// Create a request whose deadline passes before its query runs
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/employees/2", nil))
+
+	// Check the query stopped at the deadline and the handler answered 503
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "Request timed out\n", rr.Body.String())
+}
+
+func TestRouteTimeouts_PASS_Streams_Exempt(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(routeTimeouts(time.Nanosecond, nil))
+	hasDeadline := func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		w.Header().Set("X-Deadline", strconv.FormatBool(ok))
+	}
+	r.Get("/employees/stream", hasDeadline)
+	r.Get("/getEmployees", hasDeadline)
+	r.Post("/employees/import.ndjson", hasDeadline)
+
+	// Check streaming responses run without a deadline, the others with one
+	for target, deadline := range map[string]string{
+		"GET /employees/stream":                       "false",
+		"GET /getEmployees?stream=true":               "false",
+		"POST /employees/import.ndjson?progress=true": "false",
+		"GET /getEmployees":                           "true",
+		"POST /employees/import.ndjson":               "true",
+	} {
+		method, path, _ := strings.Cut(target, " ")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		assert.Equal(t, deadline, rr.Header().Get("X-Deadline"), target)
+	}
+}
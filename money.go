@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cents Type:
+// A money amount in whole cents. It is stored as an integer so sums and
+// comparisons are exact, and is read and written as a decimal in JSON.
+type Cents int64
+
+// Write the amount as a decimal number such as 50000.01
+func (c Cents) MarshalJSON() ([]byte, error) {
+	sign := ""
+	value := int64(c)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return []byte(fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)), nil
+}
+
+// Read a decimal number, rounding it to whole cents without going through a float
+func (c *Cents) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return errors.New("salary must be a number")
+	}
+	text := number.String()
+	if strings.ContainsAny(text, "eE") {
+		amount, err := number.Float64()
+		if err != nil {
+			return err
+		}
+		*c = Cents(toCents(amount))
+		return nil
+	}
+	cents, err := decimalToCents(text)
+	if err != nil {
+		return err
+	}
+	*c = Cents(cents)
+	return nil
+}
+
+// Format the amount as a decimal such as 50000.01
+func (c Cents) String() string {
+	text, _ := c.MarshalJSON()
+	return string(text)
+}
+
+// The amount in whole units, for display and arithmetic that can be approximate
+func (c Cents) Float64() float64 {
+	return float64(c) / 100
+}
+
+// Round the amount to whole cents, half away from zero. The rounding is done
+// on the shortest decimal representation of the float, so 50000.005 becomes
+// 5000001 cents even though the float is slightly below 50000.005.
+func toCents(amount float64) int64 {
+	cents, _ := decimalToCents(strconv.FormatFloat(amount, 'f', -1, 64))
+	return cents
+}
+
+// Parse a decimal string such as "-12.345" into cents, rounding half away from zero
+func decimalToCents(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, errors.New("invalid amount " + strconv.Quote(s))
+		}
+	}
+
+	fraction += "000"
+	cents, err := strconv.ParseInt(whole+fraction[:2], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if fraction[2] >= '5' {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToCents_PASS(t *testing.T) {
+	tests := map[float64]int64{
+		50000.005: 5000001,
+		50000.004: 5000000,
+		0.1 + 0.2: 30,
+		1.005:     101,
+		-2.675:    -268,
+		60000:     6000000,
+	}
+	for amount, cents := range tests {
+		assert.Equal(t, cents, toCents(amount), "amount %v", amount)
+	}
+}
+
+func TestCents_PASS_JSON_Exact_Values(t *testing.T) {
+	tests := map[string]Cents{
+		`0.1`:       10,
+		`0.2`:       20,
+		`0.30`:      30,
+		`50000.005`: 5000001,
+		`19.99`:     1999,
+		`-0.01`:     -1,
+		`5e4`:       5000000,
+		`70000`:     7000000,
+	}
+	for input, expected := range tests {
+		var amount Cents
+		assert.Nil(t, json.Unmarshal([]byte(input), &amount), input)
+		assert.Equal(t, expected, amount, input)
+	}
+
+	// Sums of cents are exact where 0.1 + 0.2 as floats is not
+	var a, b Cents
+	json.Unmarshal([]byte("0.1"), &a)
+	json.Unmarshal([]byte("0.2"), &b)
+	out, _ := json.Marshal(a + b)
+	assert.Equal(t, "0.30", string(out))
+
+	out, _ = json.Marshal(Cents(-1))
+	assert.Equal(t, "-0.01", string(out))
+}
+
+func TestCents_FAIL_Not_A_Number(t *testing.T) {
+	var amount Cents
+	assert.NotNil(t, json.Unmarshal([]byte(`"lots"`), &amount))
+}
+
+func TestCreateEmployeeHandler_PASS_Salary_Stored_As_Cents(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create an employee with a salary below a cent
+	reqBody := []byte(`{"id":1,"name":"John Doe","position":"Engineer","salary":50000.005}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Read it back
+	req = httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check the stored and returned salary is the rounded amount
+	assert.Contains(t, rr.Body.String(), `"salary":50000.01`)
+	var cents int64
+	db.QueryRow("SELECT salary_cents FROM employees WHERE id = 1").Scan(&cents)
+	assert.Equal(t, int64(5000001), cents)
+}
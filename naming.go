@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// JSON key naming policies
+const (
+	namingCamelCase = "camel_case"
+	namingSnakeCase = "snake_case"
+)
+
+// Naming policy for the response, asked for with an Accept parameter such as
+// "application/json; naming=snake_case", otherwise the configured default
+func (h *Handler) jsonNaming(r *http.Request) string {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch params["naming"] {
+		case namingCamelCase, namingSnakeCase:
+			return params["naming"]
+		}
+	}
+	if h.cfg.JSONNaming == namingSnakeCase {
+		return namingSnakeCase
+	}
+	return namingCamelCase
+}
+
+// Apply the naming policy to v, which is returned unchanged for camelCase
+func (h *Handler) applyNaming(r *http.Request, v interface{}) interface{} {
+	if h.jsonNaming(r) != namingSnakeCase {
+		return v
+	}
+	data, err := snakeCaseJSON(v)
+	if err != nil {
+		return v
+	}
+	return data
+}
+
+// Marshal v with every object key converted to snake_case
+func snakeCaseJSON(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Decode numbers as written so salaries keep their two decimals
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(snakeCaseKeys(generic))
+}
+
+func snakeCaseKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			converted[snakeCase(key)] = snakeCaseKeys(field)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = snakeCaseKeys(item)
+		}
+		return value
+	}
+	return v
+}
+
+// Convert a camelCase name such as managerId to manager_id
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Get employee 2 with the given Accept header and return the body keys
+func getEmployeeKeys(t *testing.T, handler Handler, accept string) map[string]json.RawMessage {
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	handler.getEmployeeByIdHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &keys); err != nil {
+		t.Fatalf("Error unmarshalling JSON: %v", err)
+	}
+	return keys
+}
+
+func TestJSONNaming_PASS_Camel_Case_By_Default(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	keys := getEmployeeKeys(t, handler, "")
+	assert.Contains(t, keys, "hireDate")
+	assert.Contains(t, keys, "managerId")
+	assert.NotContains(t, keys, "hire_date")
+}
+
+func TestJSONNaming_PASS_Snake_Case_From_Accept(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	keys := getEmployeeKeys(t, handler, "application/json; naming=snake_case")
+	assert.Contains(t, keys, "hire_date")
+	assert.Contains(t, keys, "manager_id")
+	assert.Contains(t, keys, "created_at")
+	assert.NotContains(t, keys, "hireDate")
+	// Salaries keep their decimals
+	assert.Equal(t, "60000.00", string(keys["salary"]))
+}
+
+func TestJSONNaming_PASS_Snake_Case_From_Config(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{JSONNaming: namingSnakeCase}}
+	defer handler.db.Close()
+
+	assert.Contains(t, getEmployeeKeys(t, handler, ""), "hire_date")
+	// The Accept header wins over the configured default
+	assert.Contains(t, getEmployeeKeys(t, handler, "application/json; naming=camel_case"), "hireDate")
+}
+
+func TestJSONNaming_PASS_Snake_Case_List_Body(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	for _, envelope := range []bool{false, true} {
+		handler := Handler{db: db, cfg: Config{JSONNaming: namingSnakeCase, Envelope: envelope}}
+		req := httptest.NewRequest("GET", "/getEmployees?size=1&includeCost=true", nil)
+		rr := httptest.NewRecorder()
+		handler.getEmployeesListHandler(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		// The wrapper and meta keys follow the policy along with the employees
+		var body struct {
+			Data []map[string]json.RawMessage `json:"data"`
+			Meta map[string]json.RawMessage   `json:"meta"`
+		}
+		var keys map[string]json.RawMessage
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		json.Unmarshal(rr.Body.Bytes(), &keys)
+		if envelope {
+			keys = body.Meta
+		}
+		for _, key := range []string{"total_pages", "total_salary", "out_of_range"} {
+			assert.Contains(t, keys, key, envelope)
+		}
+		for _, key := range []string{"totalPages", "totalSalary", "outOfRange"} {
+			assert.NotContains(t, keys, key, envelope)
+		}
+		assert.Contains(t, body.Data[0], "hire_date", envelope)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "id", snakeCase("id"))
+	assert.Equal(t, "manager_id", snakeCase("managerId"))
+	assert.Equal(t, "first_name", snakeCase("firstName"))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Writes a JSON array one element at a time, flushing after each so the
+// client receives data before the whole result has been read
+type jsonArrayStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	count   int
+}
+
+func newJSONArrayStream(w http.ResponseWriter) *jsonArrayStream {
+	flusher, _ := w.(http.Flusher)
+	return &jsonArrayStream{w: w, flusher: flusher}
+}
+
+// Write the next element, opening the array on the first one
+func (s *jsonArrayStream) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if s.count == 0 {
+		s.w.Header().Set("Content-Type", "application/json")
+		s.w.WriteHeader(http.StatusOK)
+		separator = "["
+	}
+	s.count++
+	if _, err := s.w.Write([]byte(separator)); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+// Close the array, writing [] when nothing was written
+func (s *jsonArrayStream) close() {
+	if s.count == 0 {
+		s.w.Header().Set("Content-Type", "application/json")
+		s.w.WriteHeader(http.StatusOK)
+		s.w.Write([]byte("["))
+	}
+	s.w.Write([]byte("]\n"))
+	s.flush()
+}
+
+func (s *jsonArrayStream) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// Employee as returned to callers not allowed to see salaries. The Salary
+// field shadows the embedded one and is always omitted.
+type redactedEmployee struct {
+	Employee
+	Salary *struct{} `json:"salary,omitempty"`
+}
+
+// Whether salaries must be hidden from the caller
+func (h *Handler) redactSalary(r *http.Request) bool {
+	return h.cfg.RedactSalary && !h.isAdmin(r)
+}
+
+// The employee with its timestamps in the configured timezone
+func (h *Handler) inTimezone(emp Employee) Employee {
+	location := h.cfg.timezone()
+	emp.CreatedAt.Time = emp.CreatedAt.In(location)
+	emp.UpdatedAt.Time = emp.UpdatedAt.In(location)
+	return emp
+}
+
+// Shape the employee for the caller, hiding what they may not see
+func (h *Handler) shapeEmployee(r *http.Request, emp Employee) interface{} {
+	emp = h.inTimezone(emp)
+	if h.redactSalary(r) {
+		return h.applyNaming(r, redactedEmployee{Employee: emp})
+	}
+	return h.applyNaming(r, emp)
+}
+
+// Employee with their manager embedded, for ?expand=manager
+type employeeWithManager struct {
+	Employee
+	Manager *Employee `json:"manager"`
+}
+
+// Same as employeeWithManager with both salaries hidden
+type redactedEmployeeWithManager struct {
+	redactedEmployee
+	Manager *redactedEmployee `json:"manager"`
+}
+
+// Shape the employee and their manager, nil when they have none, for the caller
+func (h *Handler) shapeEmployeeWithManager(r *http.Request, emp Employee, manager *Employee) interface{} {
+	emp = h.inTimezone(emp)
+	if manager != nil {
+		local := h.inTimezone(*manager)
+		manager = &local
+	}
+	if !h.redactSalary(r) {
+		return h.applyNaming(r, employeeWithManager{Employee: emp, Manager: manager})
+	}
+	redacted := redactedEmployeeWithManager{redactedEmployee: redactedEmployee{Employee: emp}}
+	if manager != nil {
+		redacted.Manager = &redactedEmployee{Employee: *manager}
+	}
+	return h.applyNaming(r, redacted)
+}
+
+// Same as RankedEmployee with the salary hidden. The rank is kept, it only
+// tells how salaries compare.
+type redactedRankedEmployee struct {
+	redactedEmployee
+	Rank int `json:"rank"`
+}
+
+// Shape a list of ranked employees for the caller
+func (h *Handler) shapeRankedEmployees(r *http.Request, ranked []RankedEmployee) interface{} {
+	local := make([]RankedEmployee, len(ranked))
+	for i, emp := range ranked {
+		local[i] = RankedEmployee{Employee: h.inTimezone(emp.Employee), Rank: emp.Rank}
+	}
+	if !h.redactSalary(r) {
+		return h.applyNaming(r, local)
+	}
+	redacted := make([]redactedRankedEmployee, len(local))
+	for i, emp := range local {
+		redacted[i] = redactedRankedEmployee{redactedEmployee: redactedEmployee{Employee: emp.Employee}, Rank: emp.Rank}
+	}
+	return h.applyNaming(r, redacted)
+}
+
+// Shape a list of employees for the caller
+func (h *Handler) shapeEmployees(r *http.Request, employees []Employee) interface{} {
+	local := make([]Employee, len(employees))
+	for i, emp := range employees {
+		local[i] = h.inTimezone(emp)
+	}
+	if !h.redactSalary(r) {
+		return h.applyNaming(r, local)
+	}
+	redacted := make([]redactedEmployee, len(local))
+	for i, emp := range local {
+		redacted[i] = redactedEmployee{Employee: emp}
+	}
+	return h.applyNaming(r, redacted)
+}
+
+// Same as DepartmentSalaries with the figures hidden. The count is kept, a
+// department of one would otherwise give away that employee's salary.
+type redactedDepartmentSalaries struct {
+	DepartmentSalaries
+	Total   *struct{} `json:"total,omitempty"`
+	Average *struct{} `json:"average,omitempty"`
+}
+
+// Shape the salaries per department for the caller
+func (h *Handler) shapeDepartmentSalaries(r *http.Request, departments []DepartmentSalaries) interface{} {
+	if !h.redactSalary(r) {
+		return h.applyNaming(r, departments)
+	}
+	redacted := make([]redactedDepartmentSalaries, len(departments))
+	for i, department := range departments {
+		redacted[i] = redactedDepartmentSalaries{DepartmentSalaries: department}
+	}
+	return h.applyNaming(r, redacted)
+}
+
+// Shape a list of employees as an object keyed by ID, for clients that look
+// employees up directly
+func (h *Handler) shapeEmployeesByID(r *http.Request, employees []Employee) map[string]interface{} {
+	byID := make(map[string]interface{}, len(employees))
+	for _, emp := range employees {
+		byID[strconv.Itoa(emp.ID)] = h.shapeEmployee(r, emp)
+	}
+	return byID
+}
+
+// Body of every resource and list response when the envelope is enabled
+type responseEnvelope struct {
+	Data interface{}            `json:"data"`
+	Meta map[string]interface{} `json:"meta"`
+}
+
+// Wrap data in the envelope when enabled, otherwise return it bare. meta may
+// be nil and is then sent as an empty object.
+func (h *Handler) envelope(data interface{}, meta map[string]interface{}) interface{} {
+	if !h.cfg.Envelope {
+		return data
+	}
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	return responseEnvelope{Data: data, Meta: meta}
+}
+
+// Answer with one page of a list. Every paginated list goes through here or
+// writeWrappedPage, so they all carry the same X-Total-Count header and meta
+// keys, all built by pageMeta. A page
+// starting past the last item is flagged as out of range, in the meta and in
+// an X-Page-Out-Of-Range header for clients not using the envelope. A page
+// cut down to ABSOLUTE_MAX_RESULTS is flagged as clamped the same way. The
+// meta also gives the number of pages of this size and, unless the request
+// used offset and limit, the page asked for.
+func (h *Handler) writePage(w http.ResponseWriter, r *http.Request, body interface{}, total int, size int, offset int, clamped bool) {
+	h.writePageWithMeta(w, r, body, total, size, offset, clamped, nil)
+}
+
+// Same as writePage with extra entries in the meta
+func (h *Handler) writePageWithMeta(w http.ResponseWriter, r *http.Request, body interface{}, total int, size int, offset int,
+	clamped bool, extra map[string]interface{}) {
+	h.writeJSON(w, r, http.StatusOK, h.envelope(body, pageMeta(w, r, total, size, offset, clamped, extra)))
+}
+
+// Same as writePageWithMeta, except that without the envelope the meta goes
+// next to the data, as in {"data":[...],"page":1,"size":10,"total":42,...}
+func (h *Handler) writeWrappedPage(w http.ResponseWriter, r *http.Request, body interface{}, total int, size int, offset int,
+	clamped bool, extra map[string]interface{}) {
+	meta := pageMeta(w, r, total, size, offset, clamped, extra)
+	if h.cfg.Envelope {
+		h.writeJSON(w, r, http.StatusOK, responseEnvelope{Data: body, Meta: meta})
+		return
+	}
+	meta["data"] = body
+	h.writeJSON(w, r, http.StatusOK, meta)
+}
+
+// Set the pagination headers of a page and return its meta
+func pageMeta(w http.ResponseWriter, r *http.Request, total int, size int, offset int, clamped bool,
+	extra map[string]interface{}) map[string]interface{} {
+	outOfRange := offset > 0 && offset >= total
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if outOfRange {
+		w.Header().Set("X-Page-Out-Of-Range", "true")
+	}
+	if clamped {
+		w.Header().Set("X-Results-Clamped", "true")
+	}
+	meta := map[string]interface{}{"total": total, "size": size, "offset": offset, "outOfRange": outOfRange,
+		"clamped": clamped, "totalPages": (total + size - 1) / size}
+	if page := requestedPage(r); page > 0 {
+		meta["page"] = page
+	}
+	for key, value := range extra {
+		meta[key] = value
+	}
+	return meta
+}
+
+// Write v as the JSON response body with the given status, its keys, those
+// of the envelope and pagination meta included, named by the policy of r
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	writeJSON(w, status, h.applyNaming(r, v))
+}
+
+// Write v as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Whether the client sent Prefer: return=minimal, asking for a write to be
+// answered without the resource. The header is acknowledged with
+// Preference-Applied.
+func preferMinimal(w http.ResponseWriter, r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "return=minimal") {
+				w.Header().Set("Preference-Applied", "return=minimal")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Answer with a 500 carrying only a generated reference. The details, which
+// can reveal the schema or file paths, go to the log under that reference.
+// An error from the request running past its deadline is a 503 instead.
+func internalError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Request timed out", http.StatusServiceUnavailable)
+		return
+	}
+	ref := newErrorRef()
+	log.Printf("%s (ref %s): %v", message, ref, err)
+	http.Error(w, "internal error, ref: "+ref, http.StatusInternalServerError)
+}
+
+// Short random reference tying a response to its log line
+func newErrorRef() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
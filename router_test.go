@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Full router with an in-memory database, served over a real listener
+func newTestServer(t *testing.T, cfg Config) *httptest.Server {
+	db := setupDatabase()
+	server := httptest.NewServer(newRouter(newHandler(cfg, db)))
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+	})
+	return server
+}
+
+// Send a request to the test server, returning the status and body
+func doRequest(t *testing.T, server *httptest.Server, method string, path string, body string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestRouter_PASS_Every_Route(t *testing.T) {
+	server := newTestServer(t, Config{TestMode: true, AdminToken: "secret", Features: allFeatures()})
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	// Run in order, later steps rely on what earlier ones changed
+	steps := []struct {
+		method   string
+		path     string
+		body     string
+		header   http.Header
+		status   int
+		contains string
+	}{
+		{"GET", "/", "", nil, http.StatusOK, `"GET /employees/{id}"`},
+		{"POST", "/createEmployee", `{"id":1,"name":"John Doe","position":"Engineer","salary":50000,"department":"Sales"}`, nil, http.StatusCreated, ""},
+		{"GET", "/employees/1", "", nil, http.StatusOK, `"name":"John Doe"`},
+		{"GET", "/employees/2.vcf", "", nil, http.StatusOK, "BEGIN:VCARD"},
+		{"GET", "/employees/feed.atom", "", nil, http.StatusOK, "<title>John Doe</title>"},
+		{"POST", "/updateEmployee", `{"id":1,"name":"John Smith","position":"Engineer","salary":50000,"department":"Sales"}`, nil, http.StatusOK, ""},
+		{"PUT", "/employees/1", `{"name":"John Smith","position":"Engineer","salary":50000,"department":"Sales"}`, nil, http.StatusOK, `"name":"John Smith"`},
+		{"PATCH", "/employees/1", `{"salary":55000}`, nil, http.StatusOK, `"salary":55000`},
+		{"POST", "/upsertEmployee", `{"id":5,"name":"Eve","position":"Engineer","salary":40000}`, nil, http.StatusCreated, `"name":"Eve"`},
+		{"POST", "/employees/validate", `[{"id":7,"name":"Bob","position":"Engineer","salary":40000}]`, nil, http.StatusOK, `"valid":true`},
+		{"POST", "/employees/import.ndjson", `{"id":6,"name":"Ann","position":"Engineer","salary":40000}` + "\n", nil, http.StatusOK, `"imported":1`},
+		{"GET", "/employees/import-template.ndjson", "", nil, http.StatusOK, `"id":0,"name":""`},
+		{"POST", "/employees/bulkUpdate", `[{"id":6,"version":1,"fields":{"position":"Analyst"}}]`, nil, http.StatusMultiStatus, `"status":200`},
+		{"POST", "/employees/1/clone", "", nil, http.StatusCreated, `"name":"John Smith (copy)"`},
+		{"POST", "/employees/assignManager", `{"managerId":2,"employeeIds":[3]}`, nil, http.StatusOK, `"updated":1`},
+		{"POST", "/employees/swapPositions", `{"a":3,"b":4}`, nil, http.StatusOK, `"position":"Assistant"`},
+		{"GET", "/getEmployees?size=20", "", nil, http.StatusOK, `"name":"John Smith"`},
+		{"HEAD", "/employees", "", nil, http.StatusOK, ""},
+		{"GET", "/employees/names?search=smith", "", nil, http.StatusOK, `"name":"John Smith"`},
+		{"GET", "/employees/byYear/2021", "", nil, http.StatusOK, `"name":"Alice"`},
+		{"GET", "/employees/bySalary/2000", "", nil, http.StatusOK, `"name":"Jack"`},
+		{"GET", "/employees/startsWith/j", "", nil, http.StatusOK, `"name":"Jack"`},
+		{"GET", "/searchEmployees?name=ACK", "", nil, http.StatusOK, `"name":"Jack"`},
+		{"GET", "/employees/aboveAverage", "", nil, http.StatusOK, `"name":"Duplicate"`},
+		{"GET", "/employees/outliers?deviations=1", "", nil, http.StatusOK, `"name":"Duplicate"`},
+		{"GET", "/employees/unmanaged", "", nil, http.StatusOK, `"name":"Alice"`},
+		{"GET", "/employees/ranked", "", nil, http.StatusOK, `"rank":1`},
+		{"GET", "/employees/recent?limit=1", "", nil, http.StatusOK, `"updatedAt":"`},
+		{"POST", "/departments/rename", `{"from":"Sales","to":"Growth"}`, nil, http.StatusOK, `"updated":2`},
+		{"GET", "/departments/salaries", "", nil, http.StatusOK, `"department":"Growth"`},
+		{"GET", "/employees/bands", "", nil, http.StatusOK, `"count"`},
+		{"POST", "/employees/1/tags/remote", "", nil, http.StatusCreated, ""},
+		{"GET", "/employees/1/tags", "", nil, http.StatusOK, "remote"},
+		{"DELETE", "/employees/1/tags/remote", "", nil, http.StatusOK, ""},
+		{"GET", "/admin/integrity", "", nil, http.StatusUnauthorized, "Admin token required"},
+		{"GET", "/admin/integrity", "", admin, http.StatusOK, ""},
+		{"GET", "/admin/diagnostics", "", admin, http.StatusOK, `"rowCount"`},
+		{"POST", "/admin/recomputeSalaries", `{"op":"add","amount":1}`, admin, http.StatusOK, `"applied":true`},
+		{"POST", "/admin/webhooks/retry", "", admin, http.StatusConflict, "Webhooks are not configured"},
+		{"DELETE", "/deleteEmployee/1", "", nil, http.StatusNoContent, ""},
+		{"GET", "/employees/1", "", nil, http.StatusNotFound, "Employee does not exist."},
+		{"POST", "/test/reset", "", nil, http.StatusNoContent, ""},
+		{"GET", "/employees/44", "", nil, http.StatusNotFound, ""},
+	}
+	for _, step := range steps {
+		resp, body := doRequest(t, server, step.method, step.path, step.body, step.header)
+		name := step.method + " " + step.path
+		assert.Equal(t, step.status, resp.StatusCode, name+": "+body)
+		assert.Contains(t, body, step.contains, name)
+	}
+}
+
+func TestRouter_FAIL_Method_Not_Allowed(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	// Updates are only routed for POST
+	resp, _ := doRequest(t, server, "PUT", "/updateEmployee", `{"id":2}`, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Unknown paths are not found
+	resp, _ = doRequest(t, server, "GET", "/employees/2/unknown", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRouter_PASS_Middleware(t *testing.T) {
+	server := newTestServer(t, Config{MaxQueryBytes: 64, Features: []string{"stats"}})
+
+	// Responses are compressed and labelled as UTF-8
+	resp, body := doRequest(t, server, "GET", "/employees/unmanaged", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Uncompressed, "response should have been gzipped")
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"name":"Alice"`)
+
+	// Over-long queries are rejected before routing
+	resp, _ = doRequest(t, server, "GET", "/getEmployees?"+strings.Repeat("tag=remote&", 10), "", nil)
+	assert.Equal(t, http.StatusRequestURITooLong, resp.StatusCode)
+
+	// Charsets other than UTF-8 are not acceptable
+	resp, _ = doRequest(t, server, "GET", "/employees/2", "", http.Header{"Accept-Charset": {"iso-8859-1"}})
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+	// Feature routes are registered
+	resp, _ = doRequest(t, server, "GET", "/departments/salaries", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRouter_FAIL_No_Database(t *testing.T) {
+	server := httptest.NewServer(newRouter(&Handler{}))
+	defer server.Close()
+
+	// A handler without database answers 500 instead of panicking
+	resp, body := doRequest(t, server, "GET", "/employees/2", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, body, "database not configured")
+}
+
+func TestRouter_PASS_Event_Stream(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	// Open the stream and read the first line
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/employees/stream", nil)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream; charset=utf-8", resp.Header.Get("Content-Type"))
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "retry: 3000\n", line)
+}
+
+func TestRouter_PASS_Empty_Collections(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	db.Exec("DELETE FROM employees")
+
+	// Every collection answers an empty result with 200 and an empty array or
+	// object, and paginated ones with a zero total. The list always carries
+	// its pagination next to the data.
+	endpoints := []struct {
+		path  string
+		empty string
+		paged bool
+	}{
+		{"/getEmployees", "[]", true},
+		{"/getEmployees?department=Sales&minSalary=50000", "[]", true},
+		{"/getEmployees?as=map", "{}", true},
+		{"/employees/names?search=alice", "[]", true},
+		{"/employees/byYear/2021", "[]", true},
+		{"/employees/bySalary/1000", "[]", true},
+		{"/searchEmployees?name=alice", "[]", true},
+		{"/employees/aboveAverage", "[]", true},
+		{"/employees/outliers", "[]", true},
+		{"/employees/unmanaged", "[]", true},
+		{"/employees/ranked", "[]", true},
+		{"/departments/salaries", "[]", false},
+	}
+	for _, envelope := range []bool{false, true} {
+		router := newRouter(newHandler(Config{Envelope: envelope, Features: allFeatures()}, db))
+		for _, endpoint := range endpoints {
+			req := httptest.NewRequest("GET", endpoint.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code, endpoint.path)
+			body := strings.TrimSpace(rr.Body.String())
+			if !envelope && !strings.HasPrefix(endpoint.path, "/getEmployees") {
+				assert.Equal(t, endpoint.empty, body, endpoint.path)
+				continue
+			}
+			assert.Contains(t, body, `"data":`+endpoint.empty, endpoint.path)
+			if endpoint.paged {
+				assert.Contains(t, body, `"total":0`, endpoint.path)
+				assert.Equal(t, "0", rr.Header().Get("X-Total-Count"), endpoint.path)
+			}
+		}
+	}
+
+	// Streams and counts are empty too
+	router := newRouter(newHandler(Config{Features: allFeatures()}, db))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/getEmployees?stream=true", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("HEAD", "/employees", nil))
+	assert.Equal(t, "0", rr.Header().Get("X-Total-Count"))
+
+	// Every salary band is still listed, with no employees in it
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/employees/bands", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 3, strings.Count(rr.Body.String(), `"count":0`))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Versions of the employee payload accepted by create and update, chosen with
+// a Content-Type such as application/vnd.employee.v1+json. Any other
+// Content-Type, or none, means the latest.
+//
+//	v1: id, name, position and salary, from before departments, hire dates and managers
+//	v2: the current Employee
+const latestEmployeeSchema = 2
+
+// Returned for an application/vnd.employee Content-Type naming no known version
+var errUnsupportedSchema = errors.New("Content-Type schema version is not supported, use " +
+	"application/vnd.employee.v1+json or application/vnd.employee.v2+json")
+
+// Employee as sent by v1 clients
+type employeeV1 struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Position string `json:"position"`
+	Salary   Cents  `json:"salary"`
+}
+
+// Columns a v1 payload sets. An update from a v1 client leaves the others as
+// they are stored, since the client does not know about them.
+var employeeV1Columns = []string{"name", "position", "salary_cents"}
+
+// Read the schema version from the Content-Type
+func employeeSchemaVersion(r *http.Request) (int, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return latestEmployeeSchema, nil
+	}
+	version, ok := strings.CutPrefix(mediaType, "application/vnd.employee.v")
+	if !ok {
+		return latestEmployeeSchema, nil
+	}
+	version, ok = strings.CutSuffix(version, "+json")
+	number, err := strconv.Atoi(version)
+	if !ok || err != nil || number < 1 || number > latestEmployeeSchema {
+		return 0, errUnsupportedSchema
+	}
+	return number, nil
+}
+
+// Decode an employee sent in the given schema version into the current Employee
+func decodeEmployee(body io.Reader, version int) (Employee, error) {
+	if version == 1 {
+		var v1 employeeV1
+		if err := json.NewDecoder(body).Decode(&v1); err != nil {
+			return Employee{}, err
+		}
+		return Employee{ID: v1.ID, Name: v1.Name, Position: v1.Position, Salary: v1.Salary}, nil
+	}
+	var employee Employee
+	err := json.NewDecoder(body).Decode(&employee)
+	return employee, err
+}
+
+// The columns of the employee an update in the given schema version sets
+func schemaColumnValues(emp Employee, version int) map[string]interface{} {
+	values := emp.columnValues()
+	if version != 1 {
+		return values
+	}
+	v1Values := make(map[string]interface{}, len(employeeV1Columns))
+	for _, column := range employeeV1Columns {
+		v1Values[column] = values[column]
+	}
+	return v1Values
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEmployeeHandler_PASS_Schema_V1(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a v1 request, newer fields are not part of that schema
+	reqBody := []byte(`{"id":1,"name":"John Doe","position":"Engineer","salary":50000,"department":"Sales"}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/vnd.employee.v1+json")
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the employee was created from the v1 fields only
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	employee, err := getEmployeeById(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "John Doe", employee.Name)
+	assert.Equal(t, Cents(50000_00), employee.Salary)
+	assert.Equal(t, "", employee.Department)
+}
+
+func TestUpdateEmployeeHandler_PASS_Schema_V1_Keeps_Newer_Fields(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Sales', manager_id = 44 WHERE id = 2")
+
+	// Create a v1 update, which knows nothing of departments or managers
+	reqBody := []byte(`{"id":2,"name":"Alice Smith","position":"Director","salary":70000}`)
+	req := httptest.NewRequest("POST", "/updateEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/vnd.employee.v1+json; charset=utf-8")
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.updateEmployeeHandler(rr, req)
+
+	// Check the v1 fields changed and the others were kept
+	assert.Equal(t, http.StatusOK, rr.Code)
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice Smith", employee.Name)
+	assert.Equal(t, "Director", employee.Position)
+	assert.Equal(t, Cents(70000_00), employee.Salary)
+	assert.Equal(t, "Sales", employee.Department)
+	assert.Equal(t, Date("2021-03-15"), employee.HireDate)
+	assert.Equal(t, 44, *employee.ManagerID)
+}
+
+func TestUpdateEmployeeHandler_PASS_Schema_Latest(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Both the explicit v2 and plain JSON are the full current schema
+	for _, contentType := range []string{"application/vnd.employee.v2+json", "application/json"} {
+		reqBody := []byte(`{"id":2,"name":"Alice","position":"Manager","salary":60000}`)
+		req := httptest.NewRequest("POST", "/updateEmployee", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		db.Exec("UPDATE employees SET department = 'Sales' WHERE id = 2")
+
+		handler.updateEmployeeHandler(rr, req)
+
+		// Check the department missing from the payload was cleared
+		assert.Equal(t, http.StatusOK, rr.Code, contentType)
+		employee, _ := getEmployeeById(db, 2)
+		assert.Equal(t, "", employee.Department, contentType)
+	}
+}
+
+func TestCreateEmployeeHandler_FAIL_Unknown_Schema(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, contentType := range []string{"application/vnd.employee.v3+json", "application/vnd.employee.vX+json",
+		"application/vnd.employee.v1+xml"} {
+		// Create a request in a schema version that does not exist
+		reqBody := []byte(`{"id":1,"name":"John Doe","position":"Engineer","salary":50000}`)
+		req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", contentType)
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.createEmployeeHandler(rr, req)
+
+		// Check the media type is refused and nothing was created
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code, contentType)
+		assert.Contains(t, rr.Body.String(), "application/vnd.employee.v1+json", contentType)
+		_, err := getEmployeeById(db, 1)
+		assert.ErrorIs(t, err, ErrEmployeeNotFound, contentType)
+	}
+}
@@ -1,228 +1,1707 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Format of Employee.HireDate
+const hireDateLayout = "2006-01-02"
+
+// Longest the server waits for in-flight requests when shutting down
+const shutdownTimeout = 30 * time.Second
+
+var yearPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
 type Handler struct {
-	db *sql.DB
+	db       *sql.DB
+	cfg      Config
+	events   *eventBroker
+	webhooks *webhookNotifier
+	cache    *employeeCache
+	creates  *createDeduper
+	ids      *idGenerator
+}
+
+// The database bound to the context of r, for the DB calls of its handler
+func (h *Handler) dbFor(r *http.Request) *requestDB {
+	return &requestDB{db: h.db, ctx: r.Context()}
 }
 
 func main() {
 	port := "3000"
+	cfg := loadConfig()
 
 	// Open DB connection
-	db, err := sql.Open("sqlite3", "./database.db")
+	db, err := sql.Open("sqlite3", "./database.db?_foreign_keys=on")
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initSchema(db); err != nil {
+		log.Fatal(err)
+	}
 
-	// Store db in a handler struct so we can use it in our handler functions in a safe way
-	handler := Handler{db: db}
+	handler := newHandler(cfg, db)
 	defer handler.db.Close()
 
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.tlsEnabled() {
+		log.Println("Starting HTTPS server on " + port)
+	} else {
+		log.Println("Starting server on " + port)
+	}
+
+	// On SIGINT or SIGTERM the contexts of in-flight requests are cancelled,
+	// so bulk operations stop at their next transaction boundary, and the
+	// server waits for them to finish before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := newServer(cfg, newRouter(handler))
+	srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		log.Println("Shutting down, draining in-flight requests")
+		drain, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(drain); err != nil {
+			log.Println("Error while draining requests:", err)
+		}
+	}()
+	if err := serve(srv, ln, cfg); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-drained
+}
+
+// Build the handler for the database, with the optional features the
+// configuration enables
+func newHandler(cfg Config, db *sql.DB) *Handler {
+	// Store db in a handler struct so we can use it in our handler functions in a safe way
+	handler := &Handler{db: db, cfg: cfg, events: newEventBroker()}
+	if cfg.WebhookURL != "" {
+		handler.webhooks = newWebhookNotifier(cfg, db)
+	}
+	if cfg.CacheTTL > 0 {
+		handler.cache = newEmployeeCache(cfg.CacheTTL)
+	}
+	if cfg.CreateDedupeWindow > 0 {
+		handler.creates = newCreateDeduper(cfg.CreateDedupeWindow)
+	}
+	switch cfg.IDStrategy {
+	case idStrategySequence:
+		handler.ids = newIDGenerator()
+	case idStrategyUUID:
+		handler.ids = newUUIDGenerator()
+	}
+	return handler
+}
+
+// Build the router serving every route of the API behind its middleware
+func newRouter(handler *Handler) http.Handler {
 	// Create a Chi Router, This handles concurrency of the mulitple requests
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(countQueriesHeader(handler.cfg.DebugQueries))
+	r.Use(limitQueryLength(handler.cfg.MaxQueryBytes))
+	r.Use(rejectSQLLikeParams(handler.cfg.StrictQueryParams))
+	r.Use(utf8Only)
+	r.Use(gzipResponses(handler.cfg.GzipMinBytes))
+	r.Use(routeTimeouts(handler.cfg.RequestTimeout, handler.cfg.RouteTimeouts))
+	r.Use(handler.requireDB)
+
+	r.Get("/", handler.indexHandler(r))
 
 	r.Post("/createEmployee", handler.createEmployeeHandler)
 
+	r.Get("/employees/stream", handler.employeeStreamHandler)
+
+	r.Get("/employees/names", handler.getEmployeeNamesHandler)
+
+	r.Post("/employees/validate", handler.validateEmployeesHandler)
+
 	r.Get("/employees/{id}", handler.getEmployeeByIdHandler)
 
+	r.Put("/employees/{id}", handler.updateEmployeeHandler)
+
+	r.Patch("/employees/{id}", handler.patchEmployeeHandler)
+
+	// Deprecated, kept for one more release in favour of PUT /employees/{id}
 	r.Post("/updateEmployee", handler.updateEmployeeHandler)
 
+	r.Post("/upsertEmployee", handler.upsertEmployeeHandler)
+
 	r.Delete("/deleteEmployee/{id}", handler.deleteEmployeeHandler)
 
+	r.Post("/employees/{id}/clone", handler.cloneEmployeeHandler)
+
+	r.Post("/employees/assignManager", handler.assignManagerHandler)
+
+	r.Post("/employees/swapPositions", handler.swapPositionsHandler)
+
 	r.Get("/getEmployees", handler.getEmployeesListHandler)
 
-	log.Println("Starting server on " + port)
-	http.ListenAndServe(":"+port, r)
+	r.Head("/employees", handler.countEmployeesHandler)
+
+	r.Get("/employees/byYear/{year}", handler.getEmployeesByYearHandler)
+
+	r.Get("/employees/bySalary/{amount}", handler.getEmployeesBySalaryHandler)
+
+	r.Get("/employees/startsWith/{letter}", handler.getEmployeesByInitialHandler)
+
+	r.Get("/searchEmployees", handler.searchEmployeesHandler)
+
+	r.Get("/employees/aboveAverage", handler.getEmployeesAboveAverageHandler)
+
+	r.Get("/employees/outliers", handler.getSalaryOutliersHandler)
+
+	r.Get("/employees/unmanaged", handler.getUnmanagedEmployeesHandler)
+
+	r.Get("/employees/ranked", handler.getRankedEmployeesHandler)
+
+	r.Get("/employees/recent", handler.getRecentEmployeesHandler)
+
+	r.Get("/enums", handler.getEnumsHandler)
+
+	r.Post("/departments/rename", handler.renameDepartmentHandler)
+
+	handler.registerFeatureRoutes(r)
+
+	r.Get("/employees/{id}/tags", handler.getEmployeeTagsHandler)
+
+	r.Post("/employees/{id}/tags/{tag}", handler.addEmployeeTagHandler)
+
+	r.Delete("/employees/{id}/tags/{tag}", handler.removeEmployeeTagHandler)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(handler.requireAdmin)
+
+		r.Get("/integrity", handler.integrityHandler)
+
+		r.Get("/diagnostics", handler.diagnosticsHandler)
+
+		r.Post("/recomputeSalaries", handler.recomputeSalariesHandler)
+
+		r.Post("/webhooks/retry", handler.retryWebhooksHandler)
+	})
+
+	r.Route("/test", func(r chi.Router) {
+		r.Use(handler.requireTestMode)
+
+		r.Post("/reset", handler.resetDatabaseHandler)
+	})
+
+	return r
 }
 
 func (h *Handler) createEmployeeHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse request
-	var employee Employee
-	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+	version, err := employeeSchemaVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	employee, err := decodeEmployee(r.Body, version)
+	if err != nil {
 		http.Error(w, "Request body is invalid", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
-	err := validateEmployee(employee)
+	err = validateEmployee(employee, h.cfg)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if h.cfg.IDStrategy == idStrategyUUID {
+		employee.UUID = newUUID()
+	}
+
+	// A conditional create asked for the ID not to exist, so an existing one
+	// fails its precondition rather than conflicting
+	ifAbsent := r.Header.Get("If-None-Match") == "*" || r.URL.Query().Get("ifAbsent") == "true"
+
 	// call DB layer
-	err = createEmployee(h.db, employee)
+	// A duplicate of a recent create gets the same response without a second insert
+	created, duplicate, err := h.creates.do(dedupeKey(employee), func() (Employee, error) {
+		if employee.ID == 0 {
+			return h.ids.create(h.dbFor(r), employee)
+		}
+		return employee, createEmployee(h.dbFor(r), employee)
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			if ifAbsent {
+				http.Error(w, "Employee with ID already exists.", http.StatusPreconditionFailed)
+				return
+			}
 			http.Error(w, "Employee with ID already exists. Error: "+
 				err.Error(), http.StatusConflict)
 			return
 		}
-		http.Error(w, "Error while inserting employee. Error: "+
-			err.Error(), http.StatusInternalServerError)
+		if isForeignKeyViolation(err) {
+			writeUnknownManager(w, employee)
+			return
+		}
+		internalError(w, "Error while inserting employee", err)
 		return
 	}
+	employee = created
+	if !duplicate {
+		h.publish(EventEmployeeCreated, employee.ID, &employee)
+	}
 
 	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Location", employeeLocation(employee))
+	if preferMinimal(w, r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// Read it back for the version and timestamps set by the DB
+	stored, err := getEmployeeById(h.dbFor(r), employee.ID)
+	if err != nil {
+		internalError(w, "Error while getting created employee", err)
+		return
+	}
+	h.writeJSON(w, r, http.StatusCreated, h.envelope(h.shapeEmployee(r, stored), nil))
+}
+
+// Answer a write whose manager_id names no employee
+func writeUnknownManager(w http.ResponseWriter, emp Employee) {
+	http.Error(w, unknownManagerMessage(emp), http.StatusUnprocessableEntity)
+}
+
+func unknownManagerMessage(emp Employee) string {
+	if emp.ManagerID == nil {
+		return "managerId does not reference an existing employee"
+	}
+	return "managerId " + strconv.Itoa(*emp.ManagerID) + " does not reference an existing employee"
+}
+
+// Path of the employee, by UUID when it has one
+func employeeLocation(emp Employee) string {
+	if emp.UUID != "" {
+		return "/employees/" + emp.UUID
+	}
+	return "/employees/" + strconv.Itoa(emp.ID)
+}
+
+// Validation result of one employee of POST /employees/validate
+type validationResult struct {
+	Index  int          `json:"index"`
+	Valid  bool         `json:"valid"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (h *Handler) validateEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var employees []Employee
+	if err := json.NewDecoder(r.Body).Decode(&employees); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if h.cfg.MaxBatchItems > 0 && len(employees) > h.cfg.MaxBatchItems {
+		http.Error(w, "A batch cannot have more than "+strconv.Itoa(h.cfg.MaxBatchItems)+" employees",
+			http.StatusBadRequest)
+		return
+	}
+
+	// Validate without touching the DB
+	results := make([]validationResult, len(employees))
+	for i, employee := range employees {
+		errs := employeeFieldErrors(employee, h.cfg)
+		results[i] = validationResult{Index: i, Valid: len(errs) == 0, Errors: errs}
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, results)
+}
+
+// One update of POST /employees/bulkUpdate. Fields holds the employee fields
+// to change, in the same JSON form as the other endpoints.
+type bulkUpdateItem struct {
+	ID      int             `json:"id"`
+	Version int             `json:"version"`
+	Fields  json.RawMessage `json:"fields"`
+}
+
+// Outcome of one update of POST /employees/bulkUpdate, Status is the HTTP
+// status the update would have got on its own
+type bulkUpdateResult struct {
+	ID      int    `json:"id"`
+	Status  int    `json:"status"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (h *Handler) bulkUpdateEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var items []bulkUpdateItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if h.cfg.MaxBatchItems > 0 && len(items) > h.cfg.MaxBatchItems {
+		http.Error(w, "A batch cannot have more than "+strconv.Itoa(h.cfg.MaxBatchItems)+" employees",
+			http.StatusBadRequest)
+		return
+	}
+
+	// Apply each update on its own, a failed one does not undo the others
+	db := h.dbFor(r)
+	results := make([]bulkUpdateResult, len(items))
+	for i, item := range items {
+		results[i] = h.applyBulkUpdate(db, item)
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusMultiStatus, results)
+}
+
+// Apply one update of a bulk update, only if the employee is still at the
+// version the client last saw
+func (h *Handler) applyBulkUpdate(db database, item bulkUpdateItem) bulkUpdateResult {
+	result := bulkUpdateResult{ID: item.ID}
+	fail := func(status int, message string) bulkUpdateResult {
+		result.Status = status
+		result.Error = message
+		return result
+	}
+	if item.Version == 0 {
+		return fail(http.StatusBadRequest, "version is required")
+	}
+	if len(item.Fields) == 0 {
+		return fail(http.StatusBadRequest, "fields cannot be empty")
+	}
+
+	// Overlay the fields on the stored employee so they are validated as a whole
+	employee, err := getEmployeeById(db, item.ID)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			return fail(http.StatusNotFound, "Employee does not exist.")
+		}
+		log.Printf("Error while getting employee %d: %v", item.ID, err)
+		return fail(http.StatusInternalServerError, "Error while updating employee")
+	}
+	if err := json.Unmarshal(item.Fields, &employee); err != nil {
+		return fail(http.StatusBadRequest, "fields are invalid")
+	}
+	employee.ID = item.ID
+	if err := validateEmployee(employee, h.cfg); err != nil {
+		return fail(http.StatusBadRequest, err.Error())
+	}
+
+	// call DB layer
+	err = updateEmployee(db, item.ID, item.Version, employee.columnValues())
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			return fail(http.StatusNotFound, "Employee does not exist.")
+		}
+		if errors.Is(err, ErrVersionConflict) {
+			return fail(http.StatusConflict, "Employee was modified by someone else, reload it and retry.")
+		}
+		if isForeignKeyViolation(err) {
+			return fail(http.StatusUnprocessableEntity, unknownManagerMessage(employee))
+		}
+		log.Printf("Error while updating employee %d: %v", item.ID, err)
+		return fail(http.StatusInternalServerError, "Error while updating employee")
+	}
+	h.cache.invalidate(item.ID)
+	employee.Version = item.Version + 1
+	h.publish(EventEmployeeUpdated, item.ID, &employee)
+
+	result.Status = http.StatusOK
+	result.Version = employee.Version
+	return result
 }
 
 func (h *Handler) getEmployeeByIdHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse Request
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	id, err := h.parseEmployeeID(r)
 	if err != nil {
-		http.Error(w, "Error parsing the ID, make sure it is an integer. Error: "+err.Error(),
-			http.StatusBadRequest)
+		writeIDParamError(w, err)
+		return
+	}
+
+	expandManager := false
+	switch r.URL.Query().Get("expand") {
+	case "":
+	case "manager":
+		expandManager = true
+	default:
+		http.Error(w, "expand must be manager", http.StatusBadRequest)
 		return
 	}
 
 	// Call DB layer
-	employee, err := getEmployeeById(h.db, id)
+	load := func(id int) (Employee, error) {
+		return getEmployeeById(h.dbFor(r), id)
+	}
+	employee, err := h.cache.get(id, load)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
+		if errors.Is(err, ErrEmployeeNotFound) {
 			http.Error(w, "Employee does not exist.",
 				http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Error while getting employee "+
-			err.Error(), http.StatusInternalServerError)
+		internalError(w, "Error while getting employee", err)
 		return
 	}
+	shaped := h.shapeEmployee(r, employee)
+	if expandManager {
+		// A manager deleted in the meantime is reported as no manager
+		var manager *Employee
+		if employee.ManagerID != nil {
+			found, err := h.cache.get(*employee.ManagerID, load)
+			if err != nil && !errors.Is(err, ErrEmployeeNotFound) {
+				internalError(w, "Error while getting manager", err)
+				return
+			}
+			if err == nil {
+				manager = &found
+			}
+		}
+		shaped = h.shapeEmployeeWithManager(r, employee, manager)
+	}
 
 	// Send Response
-	response, err := json.Marshal(employee)
+	response, err := json.Marshal(h.envelope(shaped, nil))
 	if err != nil {
-		http.Error(w, "Error while converting the db response to json. Error: "+err.Error(), http.StatusInternalServerError)
+		internalError(w, "Error while converting the db response to json", err)
+		return
 	}
-	w.Write(response)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(response)
 }
 
+// Serves both PUT /employees/{id}, where the ID comes from the path, and the
+// deprecated POST /updateEmployee, where it comes from the body
 func (h *Handler) updateEmployeeHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse Request
-	var employee Employee
-	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+	pathID := 0
+	if chi.URLParam(r, "id") != "" {
+		var err error
+		if pathID, err = h.parseEmployeeID(r); err != nil {
+			writeIDParamError(w, err)
+			return
+		}
+	} else {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</employees/{id}>; rel="successor-version"`)
+	}
+	version, err := employeeSchemaVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	employee, err := decodeEmployee(r.Body, version)
+	if err != nil {
 		http.Error(w, "Request body is invalid", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
-	err := validateEmployee(employee)
+	if chi.URLParam(r, "id") != "" {
+		if employee.ID != 0 && employee.ID != pathID {
+			http.Error(w, "The ID in the body does not match the ID in the URL", http.StatusBadRequest)
+			return
+		}
+		employee.ID = pathID
+	}
+	err = validateEmployee(employee, h.cfg)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// An explicit returnPrevious wins over Prefer: return=minimal
+	returnPrevious := r.URL.Query().Get("returnPrevious") == "true"
+	minimal := !returnPrevious && preferMinimal(w, r)
+
 	// call DB layer
-	err = updateEmployee(h.db, employee)
+	// A version in the body means the update only applies to that version
+	var previous, current Employee
+	columns := schemaColumnValues(employee, version)
+	if minimal {
+		err = updateEmployee(h.dbFor(r), employee.ID, employee.Version, columns)
+	} else {
+		previous, current, err = updateEmployeeReturningPrevious(h.dbFor(r), employee.ID, employee.Version, columns)
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
+		if errors.Is(err, ErrEmployeeNotFound) {
 			http.Error(w, "Employee does not exist.",
 				http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Error while updating employee "+
-			err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrVersionConflict) {
+			http.Error(w, "Employee was modified by someone else, reload it and retry.",
+				http.StatusConflict)
+			return
+		}
+		if isForeignKeyViolation(err) {
+			writeUnknownManager(w, employee)
+			return
+		}
+		internalError(w, "Error while updating employee", err)
 		return
 	}
+	h.cache.invalidate(employee.ID)
+	h.publish(EventEmployeeUpdated, employee.ID, &employee)
 
 	// Send Response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	if minimal {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if returnPrevious {
+		h.writeJSON(w, r, http.StatusOK, h.envelope(map[string]interface{}{
+			"previous": h.shapeEmployee(r, previous),
+			"current":  h.shapeEmployee(r, current),
+		}, nil))
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeEmployee(r, current), nil))
 }
 
-func (h *Handler) deleteEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+// Body of PATCH /employees/{id}. A nil field was omitted and is left as it is
+// stored, so a field can still be set to its zero value.
+type employeePatch struct {
+	Name       *string        `json:"name"`
+	Position   *string        `json:"position"`
+	Salary     *Cents         `json:"salary"`
+	Department *string        `json:"department"`
+	HireDate   *Date          `json:"hireDate"`
+	ManagerID  patchManagerID `json:"managerId"`
+}
+
+// The managerId of a patch. Unlike a pointer it tells an omitted managerId
+// from a null one, which removes the manager.
+type patchManagerID struct {
+	Set bool
+	ID  *int
+}
+
+func (m *patchManagerID) UnmarshalJSON(data []byte) error {
+	m.Set = true
+	return json.Unmarshal(data, &m.ID)
+}
+
+// Whether the patch sets no field at all
+func (p employeePatch) empty() bool {
+	return p.Name == nil && p.Position == nil && p.Salary == nil && p.Department == nil &&
+		p.HireDate == nil && !p.ManagerID.Set
+}
+
+// Overlay the fields of the patch on the employee
+func (p employeePatch) apply(emp Employee) Employee {
+	if p.Name != nil {
+		emp.Name = *p.Name
+	}
+	if p.Position != nil {
+		emp.Position = *p.Position
+	}
+	if p.Salary != nil {
+		emp.Salary = *p.Salary
+	}
+	if p.Department != nil {
+		emp.Department = *p.Department
+	}
+	if p.HireDate != nil {
+		emp.HireDate = *p.HireDate
+	}
+	if p.ManagerID.Set {
+		emp.ManagerID = p.ManagerID.ID
+	}
+	return emp
+}
+
+// The columns the patch sets, with their values taken from the patched employee
+func (p employeePatch) columnValues(patched Employee) map[string]interface{} {
+	values := patched.columnValues()
+	fields := make(map[string]interface{})
+	for column, set := range map[string]bool{
+		"name":         p.Name != nil,
+		"position":     p.Position != nil,
+		"salary_cents": p.Salary != nil,
+		"department":   p.Department != nil,
+		"hire_date":    p.HireDate != nil,
+		"manager_id":   p.ManagerID.Set,
+	} {
+		if set {
+			fields[column] = values[column]
+		}
+	}
+	return fields
+}
+
+func (h *Handler) patchEmployeeHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse Request
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	id, err := h.parseEmployeeID(r)
 	if err != nil {
-		http.Error(w, "Error parsing the ID, make sure it is an integer. Error: "+err.Error(),
-			http.StatusBadRequest)
+		writeIDParamError(w, err)
+		return
+	}
+	var patch employeePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		if errors.Is(err, io.EOF) {
+			http.Error(w, "Request body cannot be empty", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if patch.empty() {
+		http.Error(w, "Request body must set at least one field", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the stored employee with the patch applied, as a whole
+	stored, err := getEmployeeById(h.dbFor(r), id)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.", http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while getting employee", err)
+		return
+	}
+	patched := patch.apply(stored)
+	if err := validateEmployee(patched, h.cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// call DB layer
-	err = deleteEmployee(h.db, id)
+	err = patchEmployee(h.dbFor(r), id, patch.columnValues(patched))
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			http.Error(w, "Employee does not exist.",
-				http.StatusNotFound)
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Error while deleting employee "+
-			err.Error(), http.StatusInternalServerError)
+		if isForeignKeyViolation(err) {
+			writeUnknownManager(w, patched)
+			return
+		}
+		internalError(w, "Error while updating employee", err)
+		return
+	}
+	h.cache.invalidate(id)
+	current, err := getEmployeeById(h.dbFor(r), id)
+	if err != nil {
+		internalError(w, "Error while getting employee", err)
 		return
 	}
+	h.publish(EventEmployeeUpdated, id, &current)
 
 	// Send Response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	if preferMinimal(w, r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeEmployee(r, current), nil))
 }
 
-func (h *Handler) getEmployeesListHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) upsertEmployeeHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse Request
-	// Both are optional fields and if not present we default to page 1, size 20
-	pageNum := r.URL.Query().Get("page")
-	pageSize := r.URL.Query().Get("size")
-
-	page, err := strconv.Atoi(pageNum)
+	var employee Employee
+	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	err := validateEmployee(employee, h.cfg)
 	if err != nil {
-		page = 1 // default page
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	size, err := strconv.Atoi(pageSize)
-	if err != nil || size < 1 {
-		size = 10 // default page size
+	// Without an ID there is nothing to match, new employees go through create
+	if employee.ID == 0 {
+		http.Error(w, "Employee ID cannot be 0, use POST /createEmployee to have one assigned", http.StatusBadRequest)
+		return
 	}
 
-	offset := (page - 1) * size
-
 	// call DB layer
-	employees, err := getEmployeesList(h.db, size, offset)
+	stored, created, err := upsertEmployee(h.dbFor(r), employee)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			http.Error(w, "There are no employees.",
-				http.StatusNotFound)
+		if errors.Is(err, ErrVersionConflict) {
+			http.Error(w, "Employee was modified by someone else, reload it and retry.",
+				http.StatusConflict)
+			return
+		}
+		if isForeignKeyViolation(err) {
+			writeUnknownManager(w, employee)
 			return
 		}
-		http.Error(w, "Error while listing employee "+
-			err.Error(), http.StatusInternalServerError)
+		internalError(w, "Error while saving employee", err)
 		return
 	}
+	h.cache.invalidate(stored.ID)
 
 	// Send Response
-	json.NewEncoder(w).Encode(employees)
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
+	// The body is the stored row, so it carries the server-set version and timestamps
+	if created {
+		h.publish(EventEmployeeCreated, stored.ID, &stored)
+		h.writeJSON(w, r, http.StatusCreated, h.envelope(h.shapeEmployee(r, stored), nil))
+		return
+	}
+	h.publish(EventEmployeeUpdated, stored.ID, &stored)
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeEmployee(r, stored), nil))
 }
 
-// Validate the employee object to make sure all the fields are present
-func validateEmployee(emp Employee) error {
-	if emp.ID == 0 {
-		return errors.New("Employee ID cannot be 0")
-	}
-	if emp.Name == "" {
-		return errors.New("Employee Name cannot be blank")
+func (h *Handler) deleteEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
 	}
-	if emp.Position == "" {
-		return errors.New("Employee Position cannot be blank")
+
+	// Reports of a deleted manager move up to that manager's own manager
+	reassign := r.URL.Query().Get("reassign") == "true"
+
+	// call DB layer
+	reassigned, err := deleteEmployee(h.dbFor(r), id, reassign)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.",
+				http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrManagerHasReports) || isForeignKeyViolation(err) {
+			http.Error(w, "Employee is the manager of other employees, reassign them first or pass reassign=true.",
+				http.StatusConflict)
+			return
+		}
+		internalError(w, "Error while deleting employee", err)
+		return
 	}
-	if emp.Salary == 0 {
-		return errors.New("Employee Salary cannot be 0")
+	h.cache.invalidate(id)
+	h.publish(EventEmployeeDeleted, id, nil)
+	for _, reportID := range reassigned {
+		h.cache.invalidate(reportID)
+		h.publish(EventEmployeeUpdated, reportID, nil)
+	}
+
+	// Send Response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getEmployeesListHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	asMap := false
+	switch r.URL.Query().Get("as") {
+	case "", "array":
+	case "map":
+		asMap = true
+	default:
+		http.Error(w, "as must be array or map", http.StatusBadRequest)
+		return
+	}
+	includeCost := r.URL.Query().Get("includeCost") == "true"
+	if r.URL.Query().Get("stream") == "true" {
+		if asMap {
+			http.Error(w, "as=map cannot be streamed", http.StatusBadRequest)
+			return
+		}
+		// Without an explicit size the stream returns every employee
+		if r.URL.Query().Get("size") == "" {
+			size = -1
+		}
+		h.streamEmployeesList(w, r, filter, order, size, offset)
+		return
+	}
+
+	// Streams hold one row at a time, only pages are capped
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	// An empty page is a 200 with [], never a 404
+	employees, total, err := getEmployeesPage(h.dbFor(r), filter, order, size, offset, h.cfg.ListTotalWindow)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// The cost covers every matching employee, not only this page. It is left
+	// out for callers who may not see salaries, a filter narrowed to one
+	// employee would reveal theirs.
+	var extra map[string]interface{}
+	if includeCost && !h.redactSalary(r) {
+		totalSalary, err := sumSalaries(h.dbFor(r), filter)
+		if err != nil {
+			internalError(w, "Error while adding up salaries", err)
+			return
+		}
+		extra = map[string]interface{}{"totalSalary": totalSalary}
+		w.Header().Set("X-Total-Salary", totalSalary.String())
+	}
+
+	// Send Response
+	var body interface{} = h.shapeEmployees(r, employees)
+	if asMap {
+		body = h.shapeEmployeesByID(r, employees)
+	}
+	h.writeWrappedPage(w, r, body, total, size, offset, clamped, extra)
+}
+
+// Answer HEAD /employees with the number of employees matching the filters of
+// GET /getEmployees, so clients can size their pagination without a page
+func (h *Handler) countEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// call DB layer
+	total, err := countEmployees(h.dbFor(r), filter)
+	if err != nil {
+		internalError(w, "Error while counting employees", err)
+		return
+	}
+
+	// Send Response
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) getEmployeeNamesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// call DB layer
+	names, total, err := getEmployeeNames(h.dbFor(r), filter, order, size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee names", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, names, total, size, offset, clamped)
+}
+
+func (h *Handler) getEmployeesByYearHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	year := chi.URLParam(r, "year")
+	if !yearPattern.MatchString(year) {
+		http.Error(w, "Year must be four digits", http.StatusBadRequest)
+		return
+	}
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getEmployeesByHireYear(h.dbFor(r), year, size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+func (h *Handler) getEmployeesByInitialHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	letter := chi.URLParam(r, "letter")
+	if !isSingleLetter(letter) {
+		http.Error(w, "Letter must be a single letter", http.StatusBadRequest)
+		return
+	}
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getEmployeesByInitial(h.dbFor(r), letter, size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+// Whether the value is exactly one letter, in any alphabet
+func isSingleLetter(value string) bool {
+	letter, size := utf8.DecodeRuneInString(value)
+	return size > 0 && size == len(value) && unicode.IsLetter(letter)
+}
+
+func (h *Handler) searchEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	term := strings.TrimSpace(r.URL.Query().Get("name"))
+	if term == "" {
+		http.Error(w, "search term cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := searchEmployeesByName(h.dbFor(r), term, size, offset)
+	if err != nil {
+		internalError(w, "Error while searching employees", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+func (h *Handler) getEmployeesBySalaryHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	// A match would confirm a salary the caller may not see
+	if h.redactSalary(r) {
+		http.Error(w, "Salaries are hidden, only admins can search by salary", http.StatusForbidden)
+		return
+	}
+	salary, err := parseAmount(chi.URLParam(r, "amount"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getEmployeesBySalary(h.dbFor(r), salary, size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+func (h *Handler) getEmployeesAboveAverageHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getEmployeesAboveAverage(h.dbFor(r), size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+func (h *Handler) getSalaryOutliersHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	deviations := h.cfg.outlierDeviations()
+	if param := r.URL.Query().Get("deviations"); param != "" {
+		var err error
+		if deviations, err = strconv.ParseFloat(param, 64); err != nil || !(deviations > 0) || math.IsInf(deviations, 0) {
+			http.Error(w, "deviations must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, stats, err := getSalaryOutliers(h.dbFor(r), deviations, size, offset)
+	if err != nil {
+		internalError(w, "Error while listing salary outliers", err)
+		return
+	}
+
+	// Send Response, the statistics would give salaries away when they are hidden
+	extra := map[string]interface{}{"deviations": deviations}
+	if !h.redactSalary(r) {
+		extra["mean"], extra["stdDev"] = stats.Mean, stats.StdDev
 	}
+	h.writePageWithMeta(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped, extra)
+}
+
+// Employees returned by GET /employees/recent without a limit, and the most it returns
+const (
+	defaultRecentLimit = 5
+	maxRecentLimit     = 50
+)
+
+func (h *Handler) getRecentEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	limit := defaultRecentLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit > maxRecentLimit {
+		limit = maxRecentLimit
+		w.Header().Set("X-Results-Clamped", "true")
+	}
+
+	// call DB layer
+	employees, err := getRecentEmployees(h.dbFor(r), limit)
+	if err != nil {
+		internalError(w, "Error while listing recent employees", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeEmployees(r, employees), map[string]interface{}{"limit": limit}))
+}
+
+func (h *Handler) getRankedEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	ranked, total, err := getRankedEmployees(h.dbFor(r), size, offset)
+	if err != nil {
+		internalError(w, "Error while ranking employees", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeRankedEmployees(r, ranked), total, size, offset, clamped)
+}
+
+func (h *Handler) getUnmanagedEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	if err := h.checkPageSize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, offset := parsePagination(r)
+	size, clamped := h.clampPageSize(size)
+
+	// call DB layer
+	employees, total, err := getUnmanagedEmployees(h.dbFor(r), size, offset)
+	if err != nil {
+		internalError(w, "Error while listing employee", err)
+		return
+	}
+
+	// Send Response
+	h.writePage(w, r, h.shapeEmployees(r, employees), total, size, offset, clamped)
+}
+
+func (h *Handler) cloneEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
+	}
+
+	// call DB layer
+	clone, err := cloneEmployee(h.dbFor(r), h.ids, id)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.",
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while cloning employee", err)
+		return
+	}
+	h.publish(EventEmployeeCreated, clone.ID, &clone)
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusCreated, h.envelope(h.shapeEmployee(r, clone), nil))
+}
 
+// Request body of POST /employees/swapPositions
+type swapPositionsRequest struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func (h *Handler) swapPositionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var request swapPositionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if request.A == 0 || request.B == 0 {
+		http.Error(w, "a and b cannot be 0", http.StatusBadRequest)
+		return
+	}
+	if request.A == request.B {
+		http.Error(w, "a and b must be different employees", http.StatusBadRequest)
+		return
+	}
+
+	// call DB layer
+	first, second, err := swapPositions(h.dbFor(r), request.A, request.B)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist. Error: "+err.Error(),
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while swapping positions", err)
+		return
+	}
+	swapped := []Employee{first, second}
+	for i := range swapped {
+		h.cache.invalidate(swapped[i].ID)
+		h.publish(EventEmployeeUpdated, swapped[i].ID, &swapped[i])
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeEmployees(r, swapped), nil))
+}
+
+// Request body of POST /employees/assignManager
+type assignManagerRequest struct {
+	ManagerID   int   `json:"managerId"`
+	EmployeeIDs []int `json:"employeeIds"`
+}
+
+func (h *Handler) assignManagerHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var request assignManagerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if request.ManagerID == 0 {
+		http.Error(w, "managerId cannot be 0", http.StatusBadRequest)
+		return
+	}
+	if len(request.EmployeeIDs) == 0 {
+		http.Error(w, "employeeIds cannot be empty", http.StatusBadRequest)
+		return
+	}
+	for _, id := range request.EmployeeIDs {
+		if id == request.ManagerID {
+			http.Error(w, "An employee cannot be their own manager", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// call DB layer
+	err := assignManager(h.dbFor(r), request.ManagerID, request.EmployeeIDs)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist. Error: "+err.Error(),
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while assigning manager", err)
+		return
+	}
+	for _, id := range request.EmployeeIDs {
+		h.cache.invalidate(id)
+		h.publish(EventEmployeeUpdated, id, nil)
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, map[string]int{"updated": len(request.EmployeeIDs)})
+}
+
+// Request body of POST /departments/rename
+type renameDepartmentRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (h *Handler) renameDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	var request renameDepartmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Request body is invalid", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if request.From == "" || request.To == "" {
+		http.Error(w, "from and to cannot be blank", http.StatusBadRequest)
+		return
+	}
+
+	// call DB layer
+	updated, err := renameDepartment(h.dbFor(r), request.From, request.To)
+	if err != nil {
+		internalError(w, "Error while renaming department", err)
+		return
+	}
+	h.cache.clear()
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, map[string]int64{"updated": updated})
+}
+
+func (h *Handler) getDepartmentSalariesHandler(w http.ResponseWriter, r *http.Request) {
+	// call DB layer
+	departments, err := getDepartmentSalaries(h.dbFor(r))
+	if err != nil {
+		internalError(w, "Error while aggregating salaries", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(h.shapeDepartmentSalaries(r, departments), nil))
+}
+
+func (h *Handler) getSalaryBandsHandler(w http.ResponseWriter, r *http.Request) {
+	// call DB layer
+	bands, err := getSalaryBands(h.dbFor(r), h.cfg.salaryBands())
+	if err != nil {
+		internalError(w, "Error while counting salary bands", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(bands, nil))
+}
+
+// Allowed values of each employee field that can be restricted, null when
+// any value is accepted
+func (h *Handler) getEnumsHandler(w http.ResponseWriter, r *http.Request) {
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(map[string][]string{
+		"position": h.cfg.PositionsAllowlist,
+	}, nil))
+}
+
+func (h *Handler) getEmployeeTagsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
+	}
+
+	// Call DB layer
+	_, err = getEmployeeById(h.dbFor(r), id)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.",
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while getting employee", err)
+		return
+	}
+	tags, err := getEmployeeTags(h.dbFor(r), id)
+	if err != nil {
+		internalError(w, "Error while listing tags", err)
+		return
+	}
+
+	// Send Response
+	h.writeJSON(w, r, http.StatusOK, h.envelope(tags, nil))
+}
+
+func (h *Handler) addEmployeeTagHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
+	}
+	tag := strings.TrimSpace(chi.URLParam(r, "tag"))
+	if tag == "" {
+		http.Error(w, "Tag cannot be blank", http.StatusBadRequest)
+		return
+	}
+
+	// call DB layer
+	err = addEmployeeTag(h.dbFor(r), id, tag)
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.",
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while tagging employee", err)
+		return
+	}
+
+	// Send Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) removeEmployeeTagHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
+	}
+	tag := chi.URLParam(r, "tag")
+
+	// call DB layer
+	err = removeEmployeeTag(h.dbFor(r), id, tag)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Employee does not have this tag.",
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while removing tag", err)
+		return
+	}
+
+	// Send Response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Stream the list as a JSON array, sending each employee as soon as it is read
+func (h *Handler) streamEmployeesList(w http.ResponseWriter, r *http.Request, filter EmployeeFilter, order EmployeeSort,
+	size int, offset int) {
+	stream := newJSONArrayStream(w)
+	err := streamEmployeesList(h.dbFor(r), filter, order, size, offset, func(employee Employee) error {
+		return stream.write(h.shapeEmployee(r, employee))
+	})
+	if err != nil {
+		if stream.count == 0 {
+			internalError(w, "Error while listing employee", err)
+			return
+		}
+		// The status is already sent, leave the array unterminated so the
+		// client sees the body is incomplete
+		log.Printf("Error while streaming employees: %v", err)
+		return
+	}
+	stream.close()
+}
+
+// Read the pagination query params and return the matching limit and offset
+// Clients can either send offset and limit, which win when present, or page
+// and size. All are optional and if not present we default to page 1, size 10
+func parsePagination(r *http.Request) (int, int) {
+	query := r.URL.Query()
+	if query.Has("offset") || query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			limit = 10 // default page size
+		}
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		return limit, offset
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 1 // default page
+	}
+
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 1 {
+		size = 10 // default page size
+	}
+
+	return size, (page - 1) * size
+}
+
+// The 1-based page asked for with page and size, 0 when the request uses
+// offset and limit instead
+func requestedPage(r *http.Request) int {
+	query := r.URL.Query()
+	if query.Has("offset") || query.Has("limit") {
+		return 0
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil {
+		page = 1 // default page
+	}
+	return page
+}
+
+// Read a money amount such as 50000.5 from a path param. Salaries are whole
+// cents, so an amount with a fraction of a cent could never match one and is
+// rejected rather than rounded.
+func parseAmount(param string) (Cents, error) {
+	amount, err := strconv.ParseFloat(param, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, errors.New("Amount must be a number")
+	}
+	cents := Cents(toCents(amount))
+	if cents.Float64() != amount {
+		return 0, errors.New("Amount cannot have more than two decimals")
+	}
+	return cents, nil
+}
+
+// Returned when a route has no {id} param, a routing bug rather than a bad request
+var errMissingIDParam = errors.New("route has no id parameter")
+
+// Read the {id} path param. With UUID IDs it may also be a UUID, which is
+// resolved to the integer ID, or ErrEmployeeNotFound when no employee has it.
+func (h *Handler) parseEmployeeID(r *http.Request) (int, error) {
+	param := chi.URLParam(r, "id")
+	if param == "" {
+		return 0, errMissingIDParam
+	}
+	if h.cfg.IDStrategy != idStrategyUUID || !uuidPattern.MatchString(param) {
+		return strconv.Atoi(param)
+	}
+	id, err := getEmployeeIDByUUID(h.dbFor(r), param)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEmployeeNotFound
+	}
+	return id, err
+}
+
+// Answer a failed parseEmployeeID, with a 500 when the route itself is broken
+func writeIDParamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrEmployeeNotFound) {
+		http.Error(w, "Employee does not exist.", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errMissingIDParam) {
+		internalError(w, "Error reading the ID", err)
+		return
+	}
+	http.Error(w, "Error parsing the ID, make sure it is an integer. Error: "+err.Error(),
+		http.StatusBadRequest)
+}
+
+// Lower the page size to ABSOLUTE_MAX_RESULTS, whatever the client asked for,
+// reporting whether it was lowered
+func (h *Handler) clampPageSize(size int) (int, bool) {
+	if h.cfg.AbsoluteMaxResults > 0 && size > h.cfg.AbsoluteMaxResults {
+		return h.cfg.AbsoluteMaxResults, true
+	}
+	return size, false
+}
+
+// With strict pagination, reject a size or limit that is present but not a
+// positive integer instead of silently using the default
+func (h *Handler) checkPageSize(r *http.Request) error {
+	if !h.cfg.StrictPagination {
+		return nil
+	}
+	for _, param := range []string{"size", "limit"} {
+		if !r.URL.Query().Has(param) {
+			continue
+		}
+		if value, err := strconv.Atoi(r.URL.Query().Get(param)); err != nil || value < 1 {
+			return errors.New(param + " must be a positive integer")
+		}
+	}
 	return nil
 }
+
+// Read the tag, search, department, minSalary, idFrom and idTo query params,
+// all optional
+func parseFilter(r *http.Request) (EmployeeFilter, error) {
+	filter := EmployeeFilter{Tag: r.URL.Query().Get("tag"), Search: r.URL.Query().Get("search"),
+		Department: r.URL.Query().Get("department"), Position: r.URL.Query().Get("position")}
+	var err error
+	if minSalary := r.URL.Query().Get("minSalary"); minSalary != "" {
+		cents, err := decimalToCents(minSalary)
+		if err != nil {
+			return filter, errors.New("minSalary must be an amount such as 50000.00")
+		}
+		filter.MinSalary = Cents(cents)
+	}
+	if idFrom := r.URL.Query().Get("idFrom"); idFrom != "" {
+		if filter.IDFrom, err = strconv.Atoi(idFrom); err != nil {
+			return filter, errors.New("idFrom must be an integer")
+		}
+	}
+	if idTo := r.URL.Query().Get("idTo"); idTo != "" {
+		if filter.IDTo, err = strconv.Atoi(idTo); err != nil {
+			return filter, errors.New("idTo must be an integer")
+		}
+	}
+	if filter.IDFrom != 0 && filter.IDTo != 0 && filter.IDFrom > filter.IDTo {
+		return filter, errors.New("idFrom cannot be greater than idTo")
+	}
+	return filter, nil
+}
+
+// Read the sortBy and order query params, both optional
+func parseSort(r *http.Request) (EmployeeSort, error) {
+	var order EmployeeSort
+	sortBy := r.URL.Query().Get("sortBy")
+	if sortBy != "" {
+		if _, ok := sortColumns[sortBy]; !ok {
+			return order, errors.New("sortBy must be one of " + strings.Join(sortTokens(), ", "))
+		}
+		order.By = sortBy
+	}
+
+	switch strings.ToLower(r.URL.Query().Get("order")) {
+	case "", "asc":
+	case "desc":
+		order.Descending = true
+	default:
+		return order, errors.New("order must be asc or desc")
+	}
+	return order, nil
+}
+
+// The accepted sortBy values in a stable order for error messages
+func sortTokens() []string {
+	tokens := make([]string, 0, len(sortColumns))
+	for token := range sortColumns {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldError Struct:
+// A validation failure of one employee field.
+type FieldError struct {
+	//JSON name of the invalid field.
+	Field string `json:"field"`
+	//Why the value was rejected.
+	Message string `json:"message"`
+}
+
+// Validate the employee object to make sure all the fields are present
+func validateEmployee(emp Employee, cfg Config) error {
+	if errs := employeeFieldErrors(emp, cfg); len(errs) > 0 {
+		return errors.New(errs[0].Message)
+	}
+	return nil
+}
+
+// Check every field of the employee, returning all the failures
+func employeeFieldErrors(emp Employee, cfg Config) []FieldError {
+	errs := []FieldError{}
+	add := func(field string, message string) {
+		errs = append(errs, FieldError{Field: field, Message: message})
+	}
+	// With UUIDs or sequence IDs the ID can be left for the server to assign
+	if emp.ID == 0 && cfg.IDStrategy != idStrategyUUID && cfg.IDStrategy != idStrategySequence {
+		add("id", "Employee ID cannot be 0")
+	}
+	if emp.Name == "" {
+		add("name", "Employee Name cannot be blank")
+	}
+	if emp.Position == "" {
+		add("position", "Employee Position cannot be blank")
+	} else if len(cfg.PositionsAllowlist) > 0 && !containsString(cfg.PositionsAllowlist, emp.Position) {
+		add("position", "Employee Position must be one of "+strings.Join(cfg.PositionsAllowlist, ", "))
+	}
+	if emp.Salary == 0 {
+		add("salary", "Employee Salary cannot be 0")
+	} else if emp.Salary < cfg.salaryMin() {
+		add("salary", fmt.Sprintf("Employee Salary must be at least the minimum of %s", cfg.salaryMin()))
+	} else if emp.Salary > cfg.salaryMax() {
+		add("salary", fmt.Sprintf("Employee Salary cannot exceed the maximum of %s", cfg.salaryMax()))
+	}
+	if emp.ManagerID != nil && *emp.ManagerID == emp.ID {
+		add("managerId", "Employee cannot be their own manager")
+	}
+	if emp.HireDate != "" && !emp.HireDate.valid() {
+		add("hireDate", "Employee HireDate must be formatted as "+inputTimeFormats)
+	}
+
+	return errs
+}
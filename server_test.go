@@ -5,9 +5,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +27,7 @@ func TestCreateEmployeeHandler_PASS(t *testing.T) {
 	defer handler.db.Close()
 
 	// Create a new request with a JSON body
-	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000}
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
 	reqBody, _ := json.Marshal(employee)
 	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
@@ -31,8 +38,39 @@ func TestCreateEmployeeHandler_PASS(t *testing.T) {
 	// Call the handler function
 	handler.createEmployeeHandler(rr, req)
 
-	// Check the status code
+	// Check the status code and the returned employee
 	assert.Equal(t, http.StatusCreated, rr.Code)
+	var created Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "John Doe", created.Name)
+	assert.Equal(t, 1, created.Version)
+}
+
+func TestCreateEmployeeHandler_PASS_Prefer_Minimal(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request asking for no body
+	reqBody := []byte(`{"id":1,"name":"John Doe","position":"Engineer","salary":50000}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Prefer", "return=minimal")
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check only the location is returned, and the employee was still created
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "/employees/1", rr.Header().Get("Location"))
+	assert.Equal(t, "return=minimal", rr.Header().Get("Preference-Applied"))
+	assert.Empty(t, rr.Body.String())
+	_, err := getEmployeeById(db, 1)
+	assert.Nil(t, err)
 }
 
 func TestCreateEmployeeHandler_FAIL_Missing_ID(t *testing.T) {
@@ -41,7 +79,7 @@ func TestCreateEmployeeHandler_FAIL_Missing_ID(t *testing.T) {
 	defer handler.db.Close()
 
 	// Create a new request with a JSON body without ID
-	employee := Employee{Name: "John Doe", Position: "Engineer", Salary: 50000}
+	employee := Employee{Name: "John Doe", Position: "Engineer", Salary: 50000_00}
 	reqBody, _ := json.Marshal(employee)
 	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
@@ -63,7 +101,7 @@ func TestCreateEmployeeHandler_FAIL_Duplicate(t *testing.T) {
 	defer handler.db.Close()
 
 	// Create a new request with a JSON body without ID
-	employee := Employee{ID: 44, Name: "John Doe", Position: "Engineer", Salary: 50000}
+	employee := Employee{ID: 44, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
 	reqBody, _ := json.Marshal(employee)
 	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
@@ -79,39 +117,218 @@ func TestCreateEmployeeHandler_FAIL_Duplicate(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), "UNIQUE constraint failed: employees.ID")
 }
 
-// UPDATE EMPLOYEE
-func TestUpdateEmployeeHandler_PASS(t *testing.T) {
+func TestCreateEmployeeHandler_PASS_If_Absent(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a new employee object for updating
-	newEmployee := Employee{ID: 2, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000}
+	// Create a conditional create request for a new ID
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("If-None-Match", "*")
 
-	// Encode the new employee object to JSON
-	reqBody, _ := json.Marshal(newEmployee)
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("PUT", "/updateEmployee", bytes.NewReader(reqBody))
-	req.Header.Set("Content-Type", "application/json")
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestCreateEmployeeHandler_FAIL_If_Absent_Exists(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	employee := Employee{ID: 44, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody)),
+		httptest.NewRequest("POST", "/createEmployee?ifAbsent=true", bytes.NewReader(reqBody)),
+	} {
+		// Create a conditional create request for an existing ID
+		if req.URL.RawQuery == "" {
+			req.Header.Set("If-None-Match", "*")
+		}
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.createEmployeeHandler(rr, req)
+
+		// Check the precondition failed and the employee was kept
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code, req.URL.String())
+	}
+	stored, _ := getEmployeeById(db, 44)
+	assert.Equal(t, "Duplicate", stored.Name)
+}
+
+func TestCreateEmployeeHandler_FAIL_Unknown_Manager(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request referencing a manager that does not exist
+	managerID := 999
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00, ManagerID: &managerID}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.updateEmployeeHandler(rr, req)
+	handler.createEmployeeHandler(rr, req)
 
-	// Check the status code
+	// Check the violated relationship is reported
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "managerId 999 does not reference an existing employee\n", rr.Body.String())
+}
+
+// UPDATE EMPLOYEE
+func TestValidateEmployeesHandler_PASS_Mixed_Batch(t *testing.T) {
+	// No database, validation must not touch it
+	handler := Handler{}
+
+	// Create a request with a valid and two invalid employees
+	reqBody := []byte(`[
+		{"id":7,"name":"Bob","position":"Engineer","salary":50000},
+		{"id":8,"name":"","position":"","salary":50000},
+		{"id":0,"name":"Eve","position":"Analyst","salary":0,"hireDate":"15/03/2021"}
+	]`)
+	req := httptest.NewRequest("POST", "/employees/validate", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.validateEmployeesHandler(rr, req)
+
+	var results []validationResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check each employee got its own result with every failing field
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []validationResult{
+		{Index: 0, Valid: true, Errors: []FieldError{}},
+		{Index: 1, Valid: false, Errors: []FieldError{
+			{Field: "name", Message: "Employee Name cannot be blank"},
+			{Field: "position", Message: "Employee Position cannot be blank"},
+		}},
+		{Index: 2, Valid: false, Errors: []FieldError{
+			{Field: "id", Message: "Employee ID cannot be 0"},
+			{Field: "salary", Message: "Employee Salary cannot be 0"},
+			{Field: "hireDate", Message: "Employee HireDate must be formatted as " + inputTimeFormats},
+		}},
+	}, results)
 }
 
-func TestUpdateEmployeeHandler_FAIL_Employee_Doesnt_Exist(t *testing.T) {
+func TestValidateEmployeesHandler_FAIL_Too_Many(t *testing.T) {
+	handler := Handler{cfg: Config{MaxBatchItems: 2}}
+
+	// Create a request with one employee more than allowed
+	reqBody := []byte(`[
+		{"id":7,"name":"Bob","position":"Engineer","salary":50000},
+		{"id":8,"name":"Eve","position":"Analyst","salary":50000},
+		{"id":9,"name":"Dan","position":"Designer","salary":50000}
+	]`)
+	req := httptest.NewRequest("POST", "/employees/validate", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.validateEmployeesHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "A batch cannot have more than 2 employees")
+}
+
+func TestBulkUpdateEmployeesHandler_PASS_Stale_Version(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Jack was changed since the client read him at version 1
+	if err := updateEmployee(db, 3, 1, map[string]interface{}{"position": "Lead"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a request updating Alice, Jack and an employee that does not exist
+	reqBody := []byte(`[
+		{"id":2,"version":1,"fields":{"salary":65000}},
+		{"id":3,"version":1,"fields":{"name":"Jacky"}},
+		{"id":22,"version":1,"fields":{"name":"Nobody"}}
+	]`)
+	req := httptest.NewRequest("POST", "/employees/bulkUpdate", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.bulkUpdateEmployeesHandler(rr, req)
+
+	// Check the status code and the result of each item
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	var results []bulkUpdateResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []bulkUpdateResult{
+		{ID: 2, Status: http.StatusOK, Version: 2},
+		{ID: 3, Status: http.StatusConflict, Error: "Employee was modified by someone else, reload it and retry."},
+		{ID: 22, Status: http.StatusNotFound, Error: "Employee does not exist."},
+	}, results)
+
+	// Only the up to date item was stored, keeping its other fields
+	alice, _ := getEmployeeById(db, 2)
+	assert.Equal(t, Cents(65000_00), alice.Salary)
+	assert.Equal(t, "Alice", alice.Name)
+	jack, _ := getEmployeeById(db, 3)
+	assert.Equal(t, "Jack", jack.Name)
+	assert.Equal(t, "Lead", jack.Position)
+}
+
+func TestBulkUpdateEmployeesHandler_FAIL_Invalid_Items(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with a missing version and an invalid salary
+	reqBody := []byte(`[
+		{"id":2,"fields":{"name":"Alicia"}},
+		{"id":3,"version":1,"fields":{"salary":-5}}
+	]`)
+	req := httptest.NewRequest("POST", "/employees/bulkUpdate", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.bulkUpdateEmployeesHandler(rr, req)
+
+	// Check that both items were rejected without changes
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Contains(t, rr.Body.String(), `{"id":2,"status":400,"error":"version is required"}`)
+	assert.Contains(t, rr.Body.String(), `{"id":3,"status":400,`)
+	jack, _ := getEmployeeById(db, 3)
+	assert.Equal(t, 1, jack.Version)
+}
+
+func TestUpdateEmployeeHandler_PASS(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
 	// Create a new employee object for updating
-	newEmployee := Employee{ID: 99, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000}
+	newEmployee := Employee{ID: 2, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000_00}
 
 	// Encode the new employee object to JSON
 	reqBody, _ := json.Marshal(newEmployee)
@@ -126,17 +343,27 @@ func TestUpdateEmployeeHandler_FAIL_Employee_Doesnt_Exist(t *testing.T) {
 	// Call the handler function
 	handler.updateEmployeeHandler(rr, req)
 
-	// Check the status code
-	assert.Equal(t, http.StatusNotFound, rr.Code)
+	// The body-keyed route still works but is marked as deprecated
+	assert.Equal(t, "true", rr.Header().Get("Deprecation"))
+
+	// Check the status code and the returned employee
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var updated Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Alice Smith", updated.Name)
+	assert.Equal(t, 2, updated.Version)
 }
 
-func TestDeleteEmployeeHandler_PASS(t *testing.T) {
+func TestUpdateEmployeeHandler_PASS_Put_By_Path_ID(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	// Create a request carrying only the fields, the ID is in the path
+	reqBody := []byte(`{"name":"Alice Smith","position":"Senior Manager","salary":70000}`)
+	req := httptest.NewRequest("PUT", "/employees/{id}", bytes.NewReader(reqBody))
 	rctx := chi.NewRouteContext()
 	rctx.URLParams.Add("id", "2")
 
@@ -146,211 +373,3010 @@ func TestDeleteEmployeeHandler_PASS(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.deleteEmployeeHandler(rr, req)
+	handler.updateEmployeeHandler(rr, req)
 
-	// Check the status code
+	// Check the employee of the path was updated, without deprecation headers
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "", rr.Header().Get("Deprecation"))
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice Smith", employee.Name)
+	assert.Equal(t, Cents(70000_00), employee.Salary)
 }
 
-// DELETE EMPLOYEE
-func TestDeleteEmployeeHandler_FAIL_Does_Not_Exist(t *testing.T) {
+func TestUpdateEmployeeHandler_FAIL_Put_Bad_Path_ID(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	for id, message := range map[string]string{
+		"two": "Error parsing the ID, make sure it is an integer",
+		"3":   "The ID in the body does not match the ID in the URL",
+	} {
+		// Create a request whose path ID is not an integer or is not the body's
+		reqBody := []byte(`{"id":2,"name":"Alice Smith","position":"Senior Manager","salary":70000}`)
+		req := httptest.NewRequest("PUT", "/employees/{id}", bytes.NewReader(reqBody))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.updateEmployeeHandler(rr, req)
+
+		// Check nothing was updated
+		assert.Equal(t, http.StatusBadRequest, rr.Code, id)
+		assert.Contains(t, rr.Body.String(), message, id)
+	}
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice", employee.Name)
+}
+
+// Request to PATCH /employees/{id} with the body
+func patchRequest(id string, body string) *http.Request {
+	req := httptest.NewRequest("PATCH", "/employees/{id}", strings.NewReader(body))
 	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("id", "22")
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
 
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+func TestPatchEmployeeHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Sales', manager_id = 44 WHERE id = 2")
 
-	// Create a response recorder to record the response
+	// Create a request only bumping the salary
 	rr := httptest.NewRecorder()
+	handler.patchEmployeeHandler(rr, patchRequest("2", `{"salary":65000}`))
 
-	// Call the handler function
-	handler.deleteEmployeeHandler(rr, req)
-
-	// Check the status code
-	assert.Equal(t, http.StatusNotFound, rr.Code)
-	assert.Contains(t, rr.Body.String(), "Employee does not exist.")
+	// Check the salary changed and every other field was kept
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"salary":65000`)
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, Cents(65000_00), employee.Salary)
+	assert.Equal(t, "Alice", employee.Name)
+	assert.Equal(t, "Manager", employee.Position)
+	assert.Equal(t, "Sales", employee.Department)
+	assert.Equal(t, Date("2021-03-15"), employee.HireDate)
+	assert.Equal(t, 44, *employee.ManagerID)
+	assert.Equal(t, 2, employee.Version)
+
+	// Fields set to their zero value or null are changed, not skipped
+	rr = httptest.NewRecorder()
+	handler.patchEmployeeHandler(rr, patchRequest("2", `{"department":"","managerId":null}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	employee, _ = getEmployeeById(db, 2)
+	assert.Equal(t, "", employee.Department)
+	assert.Nil(t, employee.ManagerID)
+	assert.Equal(t, Cents(65000_00), employee.Salary)
 }
 
-func TestDeleteEmployeeHandler_FAIL_Invalid_Id(t *testing.T) {
+func TestPatchEmployeeHandler_FAIL(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("id", "aa")
+	for _, tc := range []struct {
+		id      string
+		body    string
+		status  int
+		message string
+	}{
+		{"2", "", http.StatusBadRequest, "Request body cannot be empty"},
+		{"2", "{}", http.StatusBadRequest, "Request body must set at least one field"},
+		{"2", `{"salary":"lots"}`, http.StatusBadRequest, "Request body is invalid"},
+		{"2", `{"name":""}`, http.StatusBadRequest, "Name"},
+		{"two", `{"salary":1}`, http.StatusBadRequest, "Error parsing the ID"},
+		{"1", `{"salary":1}`, http.StatusNotFound, "Employee does not exist."},
+		{"2", `{"managerId":99}`, http.StatusUnprocessableEntity, "managerId 99 does not reference an existing employee"},
+	} {
+		// Create a request that cannot be applied
+		rr := httptest.NewRecorder()
+		handler.patchEmployeeHandler(rr, patchRequest(tc.id, tc.body))
+
+		// Check the status code
+		assert.Equal(t, tc.status, rr.Code, tc.body)
+		assert.Contains(t, rr.Body.String(), tc.message, tc.body)
+	}
 
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	// Check nothing was changed
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice", employee.Name)
+	assert.Equal(t, 1, employee.Version)
+}
+
+func TestUpdateEmployeeHandler_PASS_Prefer_Minimal(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee asking for no body
+	reqBody := []byte(`{"id":2,"name":"Alice Smith","position":"Senior Manager","salary":70000}`)
+	req := httptest.NewRequest("POST", "/updateEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Prefer", "handling=strict, return=minimal")
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.deleteEmployeeHandler(rr, req)
+	handler.updateEmployeeHandler(rr, req)
 
-	// Check the status code
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Contains(t, rr.Body.String(), "Error parsing the ID, make sure it is an integer")
+	// Check the status code and that the update was stored
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Alice Smith", employee.Name)
 }
 
-// GET EMPLOYEE BY ID
-func TestGetEmployeeHandler_PASS(t *testing.T) {
+func TestUpdateEmployeeHandler_PASS_Return_Previous(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("GET", "/employees/{id}", nil)
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("id", "2")
-
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	// Create a request to update the employee asking for the previous state
+	employee := Employee{ID: 2, Name: "Alice", Position: "Director", Salary: 70000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/updateEmployee?returnPrevious=true", bytes.NewReader(reqBody))
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.getEmployeeByIdHandler(rr, req)
-	// Retrieve the response body
-	responseBody := rr.Body.Bytes()
+	handler.updateEmployeeHandler(rr, req)
 
-	var resultEmployee Employee
-	if err := json.Unmarshal(responseBody, &resultEmployee); err != nil {
+	var result struct {
+		Previous Employee `json:"previous"`
+		Current  Employee `json:"current"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
 		t.Errorf("Error unmarshalling JSON: %v", err)
 	}
 
-	// Check the status code
+	// Check both states are returned
 	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, resultEmployee.ID, 2)
-	assert.Equal(t, resultEmployee.Name, "Alice")
+	assert.Equal(t, "Manager", result.Previous.Position)
+	assert.Equal(t, Cents(60000_00), result.Previous.Salary)
+	assert.Equal(t, 1, result.Previous.Version)
+	assert.Equal(t, "Director", result.Current.Position)
+	assert.Equal(t, Cents(70000_00), result.Current.Salary)
+	assert.Equal(t, 2, result.Current.Version)
+
+	// Check the current state is the stored one
+	stored, _ := getEmployeeById(db, 2)
+	assert.Equal(t, stored, result.Current)
 }
 
-func TestGetEmployeeHandler_FAIL_Does_not_exist(t *testing.T) {
+func TestUpdateEmployeeHandler_FAIL_Employee_Doesnt_Exist(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("GET", "/employees/{id}", nil)
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("id", "22")
+	// Create a new employee object for updating
+	newEmployee := Employee{ID: 99, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000_00}
 
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	// Encode the new employee object to JSON
+	reqBody, _ := json.Marshal(newEmployee)
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("PUT", "/updateEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.getEmployeeByIdHandler(rr, req)
+	handler.updateEmployeeHandler(rr, req)
 
 	// Check the status code
 	assert.Equal(t, http.StatusNotFound, rr.Code)
 }
 
-// LIST EMPLOYEE
-func TestListEmployeeHandler_PASS_page1_size2(t *testing.T) {
+func TestUpdateEmployeeHandler_FAIL_Unknown_Manager(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("GET", "/getEmployees?page=1&size=2", nil)
+	// Create an update pointing the employee at a manager that does not exist
+	managerID := 999
+	newEmployee := Employee{ID: 3, Name: "Jack", Position: "Writer", Salary: 2000_00, ManagerID: &managerID}
+	reqBody, _ := json.Marshal(newEmployee)
+	req := httptest.NewRequest("POST", "/updateEmployee", bytes.NewReader(reqBody))
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.getEmployeesListHandler(rr, req)
-
-	// Retrieve the response body
-	responseBody := rr.Body.Bytes()
-
-	var resultEmployees []Employee
-	if err := json.Unmarshal(responseBody, &resultEmployees); err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
+	handler.updateEmployeeHandler(rr, req)
 
-	// Check the status code
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, len(resultEmployees), 2)
+	// Check the violated relationship is reported and nothing changed
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "managerId 999 does not reference an existing employee")
+	stored, _ := getEmployeeById(db, 3)
+	assert.Nil(t, stored.ManagerID)
 }
 
-func TestListEmployeeHandler_PASS_page2_size3(t *testing.T) {
+func TestDeleteEmployeeHandler_PASS(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
 
 	// Create a request to update the employee
-	req := httptest.NewRequest("GET", "/getEmployees?page=2&size=3", nil)
+	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.getEmployeesListHandler(rr, req)
-
-	// Retrieve the response body
-	responseBody := rr.Body.Bytes()
-
-	var resultEmployees []Employee
-	if err := json.Unmarshal(responseBody, &resultEmployees); err != nil {
-		t.Errorf("Error unmarshalling JSON: %v", err)
-	}
+	handler.deleteEmployeeHandler(rr, req)
 
-	// Check the status code
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, len(resultEmployees), 1)
+	// Check the status code and that no body is sent
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, 0, rr.Body.Len())
+	assert.Equal(t, "", rr.Header().Get("Content-Type"))
 }
 
-func TestListEmployeeHandler_PASS_default(t *testing.T) {
+func TestUpdateEmployeeHandler_FAIL_Stale_Version(t *testing.T) {
 	db := setupDatabase()
 	handler := Handler{db: db}
 	defer handler.db.Close()
+	updateEmployee(db, 2, 0, map[string]interface{}{"position": "Director"})
 
-	// Create a request to update the employee
-	req := httptest.NewRequest("GET", "/getEmployees", nil)
+	// Create an update based on version 1
+	newEmployee := Employee{ID: 2, Name: "Alice Smith", Position: "Senior Manager", Salary: 70000_00, Version: 1}
+	reqBody, _ := json.Marshal(newEmployee)
+	req := httptest.NewRequest("PUT", "/updateEmployee", bytes.NewReader(reqBody))
 
 	// Create a response recorder to record the response
 	rr := httptest.NewRecorder()
 
 	// Call the handler function
-	handler.getEmployeesListHandler(rr, req)
+	handler.updateEmployeeHandler(rr, req)
 
-	// Retrieve the response body
-	responseBody := rr.Body.Bytes()
+	// Check the status code and that the newer data is kept
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	employee, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Director", employee.Position)
+}
 
-	var resultEmployees []Employee
-	if err := json.Unmarshal(responseBody, &resultEmployees); err != nil {
+// DELETE EMPLOYEE
+func TestUpsertEmployeeHandler_PASS_Create(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to upsert a new employee
+	employee := Employee{ID: 7, Name: "Bob", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/upsertEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.upsertEmployeeHandler(rr, req)
+
+	var created Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
 		t.Errorf("Error unmarshalling JSON: %v", err)
 	}
 
-	// Check the status code
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, len(resultEmployees), 4)
+	// Check the body carries the server-set fields
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, 1, created.Version)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+	// Check the body matches the stored employee
+	stored, err := getEmployeeById(db, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, stored, created)
 }
 
-// SET UP
-func setupDatabase() *sql.DB {
-	db, _ := sql.Open("sqlite3", ":memory:")
-	_, _ = db.Exec(`CREATE TABLE IF NOT EXISTS employees (
-                        ID INTEGER PRIMARY KEY,
-                        NAME TEXT,
-                        POSITION TEXT,
-                        SALARY REAL
-                     )`)
-	db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (?, ?, ?, ?)",
-		"44", "Duplicate", "Redundant", "99999")
-	db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (?, ?, ?, ?)",
-		"2", "Alice", "Manager", "60000")
-	db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (?, ?, ?, ?)",
-		"3", "Jack", "Writer", "2000")
-	db.Exec("INSERT INTO employees (id, name, position, salary) VALUES (?, ?, ?, ?)",
-		"4", "Mary", "Assistant", "1000")
+func TestUpsertEmployeeHandler_PASS_Update(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET created_at = '2020-01-01T00:00:00.000Z' WHERE id = 2")
+
+	// Create a request to upsert an existing employee
+	employee := Employee{ID: 2, Name: "Alice", Position: "Director", Salary: 70000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/upsertEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.upsertEmployeeHandler(rr, req)
+
+	var updated Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the body carries the server-set fields
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Director", updated.Position)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, "2020-01-01T00:00:00Z", updated.CreatedAt.Format(time.RFC3339))
+	assert.True(t, updated.UpdatedAt.After(updated.CreatedAt.Time))
+
+	// Check the body matches the stored employee
+	stored, err := getEmployeeById(db, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, stored, updated)
+}
+
+func TestUpsertEmployeeHandler_FAIL_Stale_Version(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to upsert with an outdated version
+	employee := Employee{ID: 2, Name: "Alice", Position: "Director", Salary: 70000_00, Version: 5}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/upsertEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.upsertEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestDeleteEmployeeHandler_FAIL_Does_Not_Exist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "22")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.deleteEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Employee does not exist.")
+}
+
+func TestDeleteEmployeeHandler_FAIL_Referenced_Manager(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id = 3")
+
+	// Create a request to delete a manager
+	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.deleteEmployeeHandler(rr, req)
+
+	// Check the conflict is reported and the manager kept
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Employee is the manager of other employees")
+	_, err := getEmployeeById(db, 2)
+	assert.Nil(t, err)
+}
+
+func TestDeleteEmployeeHandler_PASS_Reassign_Reports(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET manager_id = 44 WHERE id = 2")
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id IN (3, 4)")
+
+	// Create a request to delete a manager, moving the reports up
+	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}?reassign=true", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.deleteEmployeeHandler(rr, req)
+
+	// Check the manager is gone and the reports now report to its manager
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	_, err := getEmployeeById(db, 2)
+	assert.ErrorIs(t, err, ErrEmployeeNotFound)
+	for _, id := range []int{3, 4} {
+		employee, _ := getEmployeeById(db, id)
+		assert.Equal(t, 44, *employee.ManagerID)
+	}
+}
+
+func TestDeleteEmployeeHandler_PASS_Concurrent_Deletes(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Delete the same employee from several requests at once
+	const attempts = 5
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "3")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+			handler.deleteEmployeeHandler(rr, req)
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	// Check exactly one delete won and the others found nothing to delete
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, map[int]int{http.StatusNoContent: 1, http.StatusNotFound: attempts - 1}, counts)
+}
+
+func TestDeleteEmployeeHandler_FAIL_Invalid_Id(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("DELETE", "/deleteEmployee/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "aa")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.deleteEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Error parsing the ID, make sure it is an integer")
+}
+
+func TestGetEmployeeHandler_FAIL_Non_Numeric_Id(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with an ID that is not a number
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "abc")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check it is the client's fault
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Error parsing the ID, make sure it is an integer")
+}
+
+func TestGetEmployeeHandler_FAIL_Missing_Id_Param(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request routed without an {id} param, as a route typo would
+	req := httptest.NewRequest("GET", "/employees/{ID}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("ID", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check it is reported as a server error
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "internal error, ref: ")
+}
+
+// GET EMPLOYEE BY ID
+func TestGetEmployeeHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+	// Retrieve the response body
+	responseBody := rr.Body.Bytes()
+
+	var resultEmployee Employee
+	if err := json.Unmarshal(responseBody, &resultEmployee); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code, and that the Content-Type was sent with it
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Result().Header.Get("Content-Type"))
+	assert.Equal(t, resultEmployee.ID, 2)
+	assert.Equal(t, resultEmployee.Name, "Alice")
+}
+
+func TestGetEmployeeHandler_FAIL_Does_not_exist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "22")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetEmployeeHandler_PASS_Expand_Manager(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id = 3")
+
+	// Create a request embedding the manager
+	req := httptest.NewRequest("GET", "/employees/{id}?expand=manager", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "3")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result struct {
+		Employee
+		Manager *Employee `json:"manager"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the embedded manager
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Jack", result.Name)
+	if assert.NotNil(t, result.Manager) {
+		assert.Equal(t, 2, result.Manager.ID)
+		assert.Equal(t, "Alice", result.Manager.Name)
+	}
+}
+
+func TestGetEmployeeHandler_PASS_Expand_Manager_None(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request embedding the manager of an employee without one
+	req := httptest.NewRequest("GET", "/employees/{id}?expand=manager", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the manager is present and null
+	assert.Equal(t, http.StatusOK, rr.Code)
+	manager, ok := result["manager"]
+	assert.True(t, ok)
+	assert.Nil(t, manager)
+}
+
+func TestGetEmployeeHandler_PASS_No_Expand(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET manager_id = 2 WHERE id = 3")
+
+	// Create a request without expand
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "3")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the manager ID is sent
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, float64(2), result["managerId"])
+	assert.NotContains(t, result, "manager")
+}
+
+func TestGetEmployeeHandler_FAIL_Unknown_Expand(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request expanding something unsupported
+	req := httptest.NewRequest("GET", "/employees/{id}?expand=tags", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// LIST EMPLOYEE
+// Decodes the data of a GET /getEmployees response into what it points to
+type listBody struct {
+	Data interface{} `json:"data"`
+}
+
+func TestListEmployeeHandler_PASS_page1_size2(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("GET", "/getEmployees?page=1&size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Retrieve the response body
+	responseBody := rr.Body.Bytes()
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(responseBody, &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, len(resultEmployees), 2)
+}
+
+func TestListEmployeeHandler_PASS_page2_size3(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("GET", "/getEmployees?page=2&size=3", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Retrieve the response body
+	responseBody := rr.Body.Bytes()
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(responseBody, &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, len(resultEmployees), 1)
+}
+
+func TestListEmployeeHandler_PASS_default(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to update the employee
+	req := httptest.NewRequest("GET", "/getEmployees", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Retrieve the response body
+	responseBody := rr.Body.Bytes()
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(responseBody, &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, len(resultEmployees), 4)
+}
+
+func TestListEmployeeHandler_PASS_size0_uses_default(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Without strict pagination size=0 falls back to the default size
+	req := httptest.NewRequest("GET", "/getEmployees?size=0", nil)
+	rr := httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+
+	var resultEmployees []Employee
+	json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &resultEmployees})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4, len(resultEmployees))
+}
+
+func TestCountEmployeesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// The header holds the seeded count and there is no body
+	req := httptest.NewRequest("HEAD", "/employees", nil)
+	rr := httptest.NewRecorder()
+	handler.countEmployeesHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "4", rr.Header().Get("X-Total-Count"))
+	assert.Empty(t, rr.Body.String())
+
+	// Filters of the list apply to the count
+	req = httptest.NewRequest("HEAD", "/employees?minSalary=50000", nil)
+	rr = httptest.NewRecorder()
+	handler.countEmployeesHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-Total-Count"))
+}
+
+func TestListEmployeeHandler_FAIL_size0_strict(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{StrictPagination: true}}
+	defer handler.db.Close()
+
+	// With strict pagination a size or limit below 1 is rejected
+	for target, message := range map[string]string{
+		"/getEmployees?size=0":    "size must be a positive integer\n",
+		"/getEmployees?limit=-1":  "limit must be a positive integer\n",
+		"/getEmployees?size=ten":  "size must be a positive integer\n",
+		"/employees/names?size=0": "size must be a positive integer\n",
+	} {
+		req := httptest.NewRequest("GET", target, nil)
+		rr := httptest.NewRecorder()
+		if strings.HasPrefix(target, "/employees/names") {
+			handler.getEmployeeNamesHandler(rr, req)
+		} else {
+			handler.getEmployeesListHandler(rr, req)
+		}
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+		assert.Equal(t, message, rr.Body.String(), target)
+	}
+
+	// A valid size is still accepted
+	req := httptest.NewRequest("GET", "/getEmployees?size=2", nil)
+	rr := httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+// Recorder keeping what had been written at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+	f.ResponseRecorder.Flush()
+}
+
+func TestListEmployeeHandler_PASS_stream(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to stream every employee
+	req := httptest.NewRequest("GET", "/getEmployees?stream=true", nil)
+
+	// Create a response recorder that records each flush
+	rr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the body was flushed element by element and parses to the full set
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4, len(resultEmployees))
+	assert.Equal(t, 5, len(rr.flushes))
+	assert.True(t, strings.HasPrefix(rr.flushes[0], `[{"id":2,`))
+	assert.NotContains(t, rr.flushes[0], "Jack")
+}
+
+func TestListEmployeeHandler_PASS_stream_empty(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to stream a tag nobody has
+	req := httptest.NewRequest("GET", "/getEmployees?stream=true&tag=none", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Check the body is an empty array
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestListEmployeeHandler_PASS_offset_limit(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	list := func(target string) []Employee {
+		req := httptest.NewRequest("GET", target, nil)
+		rr := httptest.NewRecorder()
+		handler.getEmployeesListHandler(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var resultEmployees []Employee
+		if err := json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &resultEmployees}); err != nil {
+			t.Errorf("Error unmarshalling JSON: %v", err)
+		}
+		return resultEmployees
+	}
+
+	// Check offset/limit returns the same page as the equivalent page/size
+	assert.Equal(t, list("/getEmployees?page=2&size=2"), list("/getEmployees?offset=2&limit=2"))
+	assert.Equal(t, 2, len(list("/getEmployees?offset=2&limit=2")))
+
+	// Offsets do not need to fall on a page boundary
+	employees := list("/getEmployees?offset=1&limit=2")
+	assert.Equal(t, 3, employees[0].ID)
+	assert.Equal(t, 4, employees[1].ID)
+
+	// Explicit offset/limit win over page/size
+	assert.Equal(t, list("/getEmployees?offset=3&limit=1"), list("/getEmployees?offset=3&limit=1&page=1&size=10"))
+}
+
+func TestListEmployeeHandler_PASS_sort(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list by salary, highest first
+	req := httptest.NewRequest("GET", "/getEmployees?sortBy=salary&order=desc", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the order
+	assert.Equal(t, http.StatusOK, rr.Code)
+	ids := []int{}
+	for _, employee := range resultEmployees {
+		ids = append(ids, employee.ID)
+	}
+	assert.Equal(t, []int{44, 2, 3, 4}, ids)
+}
+
+func TestListEmployeeHandler_FAIL_sortBy_injection(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request trying to smuggle SQL through sortBy
+	req := httptest.NewRequest("GET", "/getEmployees?sortBy="+url.QueryEscape("name;DROP TABLE employees"), nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Check it is rejected and the table is untouched
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "sortBy must be one of")
+	var count int
+	assert.Nil(t, db.QueryRow("SELECT COUNT(*) FROM employees").Scan(&count))
+	assert.Equal(t, 4, count)
+}
+
+func TestListEmployeeHandler_FAIL_invalid_order(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with an unknown direction
+	req := httptest.NewRequest("GET", "/getEmployees?sortBy=name&order=sideways", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "order must be asc or desc")
+}
+
+func TestListEmployeeHandler_PASS_total_header(t *testing.T) {
+	for _, useWindow := range []bool{false, true} {
+		db := setupDatabase()
+		handler := Handler{db: db, cfg: Config{ListTotalWindow: useWindow}}
+
+		// Create a request for a page smaller than the table
+		req := httptest.NewRequest("GET", "/getEmployees?page=2&size=3", nil)
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getEmployeesListHandler(rr, req)
+
+		// Check the total covers the whole table
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "4", rr.Header().Get("X-Total-Count"))
+		db.Close()
+	}
+}
+
+// SALARY BOUNDS
+func TestCreateEmployeeHandler_Salary_Bounds(t *testing.T) {
+	cfg := Config{SalaryMin: 1000_00, SalaryMax: 200000_00}
+	tests := []struct {
+		salary  Cents
+		status  int
+		message string
+	}{
+		{salary: 999_99, status: http.StatusBadRequest, message: "minimum of 1000.00"},
+		{salary: 1000_00, status: http.StatusCreated},
+		{salary: 200000_00, status: http.StatusCreated},
+		{salary: 200000_01, status: http.StatusBadRequest, message: "maximum of 200000.00"},
+	}
+
+	for i, test := range tests {
+		db := setupDatabase()
+		handler := Handler{db: db, cfg: cfg}
+
+		// Create a new request with the salary under test
+		employee := Employee{ID: 100 + i, Name: "John Doe", Position: "Engineer", Salary: test.salary}
+		reqBody, _ := json.Marshal(employee)
+		req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.createEmployeeHandler(rr, req)
+
+		// Check the status code and the violated bound
+		assert.Equal(t, test.status, rr.Code, "salary %s", test.salary)
+		assert.Contains(t, rr.Body.String(), test.message)
+		db.Close()
+	}
+}
+
+func TestCreateEmployeeHandler_FAIL_Salary_Default_Maximum(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request with an extra zero in the salary
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50_000_000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "maximum of 10000000.00")
+}
+
+// EMPLOYEES BY HIRE YEAR
+func TestEmployeesByInitialHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	createEmployee(db, Employee{ID: 5, Name: "mark", Position: "Engineer", Salary: 50000_00})
+	createEmployee(db, Employee{ID: 6, Name: "Émile", Position: "Engineer", Salary: 50000_00})
+
+	for letter, expected := range map[string][]int{"m": {4, 5}, "M": {4, 5}, "é": {6}, "z": {}} {
+		// Create a request to list the employees by initial
+		req := httptest.NewRequest("GET", "/employees/startsWith/{letter}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("letter", letter)
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getEmployeesByInitialHandler(rr, req)
+
+		var resultEmployees []Employee
+		if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+			t.Errorf("Error unmarshalling JSON: %v", err)
+		}
+
+		// Check the status code and that names match in either case
+		assert.Equal(t, http.StatusOK, rr.Code, letter)
+		ids := []int{}
+		for _, employee := range resultEmployees {
+			ids = append(ids, employee.ID)
+		}
+		assert.Equal(t, expected, ids, letter)
+	}
+}
+
+func TestEmployeesByInitialHandler_FAIL_Not_A_Letter(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, letter := range []string{"1", "%", "_", "ma", ""} {
+		// Create a request with something other than one letter
+		req := httptest.NewRequest("GET", "/employees/startsWith/{letter}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("letter", letter)
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getEmployeesByInitialHandler(rr, req)
+
+		// Check the status code
+		assert.Equal(t, http.StatusBadRequest, rr.Code, letter)
+		assert.Contains(t, rr.Body.String(), "Letter must be a single letter", letter)
+	}
+}
+
+func TestSearchEmployeesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	createEmployee(db, Employee{ID: 5, Name: "Sal_ly", Position: "Engineer", Salary: 50000_00})
+
+	for term, expected := range map[string][]int{"AL": {2, 5}, "a": {2, 3, 4}, "l_l": {5}, "%": {}, "zed": {}} {
+		// Create a request searching names, paginated like the list
+		req := httptest.NewRequest("GET", "/searchEmployees?size=3&name="+url.QueryEscape(term), nil)
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.searchEmployeesHandler(rr, req)
+
+		var resultEmployees []Employee
+		if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+			t.Errorf("Error unmarshalling JSON: %v", err)
+		}
+
+		// Check names containing the term match in any case, wildcards literally
+		assert.Equal(t, http.StatusOK, rr.Code, term)
+		ids := []int{}
+		for _, employee := range resultEmployees {
+			ids = append(ids, employee.ID)
+		}
+		assert.Equal(t, expected, ids, term)
+	}
+}
+
+func TestSearchEmployeesHandler_FAIL_Empty_Term(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, path := range []string{"/searchEmployees", "/searchEmployees?name=", "/searchEmployees?name=%20"} {
+		// Create a request without a search term
+		req := httptest.NewRequest("GET", path, nil)
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.searchEmployeesHandler(rr, req)
+
+		// Check the status code
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+		assert.Contains(t, rr.Body.String(), "search term cannot be empty", path)
+	}
+}
+
+func TestSalaryOutliersHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+	db.Exec("DELETE FROM employees")
+	for id := 1; id <= 10; id++ {
+		createEmployee(db, Employee{ID: id, Name: "Bob", Position: "Engineer", Salary: Cents(49000_00 + id*200_00)})
+	}
+	// A salary typed with three zeros too many
+	createEmployee(db, Employee{ID: 11, Name: "Eve", Position: "Engineer", Salary: 50000000_00})
+
+	// Create a request for the outliers at the default deviations
+	req := httptest.NewRequest("GET", "/employees/outliers", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getSalaryOutliersHandler(rr, req)
+
+	var result struct {
+		Data []Employee             `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the outlier is returned, with the statistics used
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, len(result.Data))
+	assert.Equal(t, 11, result.Data[0].ID)
+	assert.Equal(t, 2.0, result.Meta["deviations"])
+	assert.Contains(t, result.Meta, "mean")
+	assert.Contains(t, result.Meta, "stdDev")
+
+	// The outlier drags the mean so far that a tenth of a deviation flags everyone
+	req = httptest.NewRequest("GET", "/employees/outliers?deviations=0.1&size=20", nil)
+	rr = httptest.NewRecorder()
+	handler.getSalaryOutliersHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "11", rr.Header().Get("X-Total-Count"))
+}
+
+func TestSalaryOutliersHandler_FAIL_Invalid_Deviations(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, deviations := range []string{"0", "-1", "two", "NaN", "Inf"} {
+		// Create a request with a deviations that is not a positive number
+		req := httptest.NewRequest("GET", "/employees/outliers?deviations="+deviations, nil)
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getSalaryOutliersHandler(rr, req)
+
+		// Check the status code
+		assert.Equal(t, http.StatusBadRequest, rr.Code, deviations)
+		assert.Contains(t, rr.Body.String(), "deviations must be a positive number", deviations)
+	}
+}
+
+func TestEmployeesByYearHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list the employees hired in 2021
+	req := httptest.NewRequest("GET", "/employees/byYear/{year}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("year", "2021")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesByYearHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 2, resultEmployees[0].ID)
+	assert.Equal(t, 4, resultEmployees[1].ID)
+}
+
+func TestEmployeesByYearHandler_PASS_Empty_Year(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request for a year nobody was hired in
+	req := httptest.NewRequest("GET", "/employees/byYear/{year}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("year", "1999")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesByYearHandler(rr, req)
+
+	// Check the status code and the empty list
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestEmployeesByYearHandler_FAIL_Invalid_Year(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with a two digit year
+	req := httptest.NewRequest("GET", "/employees/byYear/{year}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("year", "21")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesByYearHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Year must be four digits")
+}
+
+// EMPLOYEES BY SALARY
+func TestEmployeesBySalaryHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, amount := range []string{"2000", "2000.0", "2000.00"} {
+		// Create a request to list the employees earning exactly 2000
+		req := httptest.NewRequest("GET", "/employees/bySalary/{amount}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("amount", amount)
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getEmployeesBySalaryHandler(rr, req)
+
+		var resultEmployees []Employee
+		if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+			t.Errorf("Error unmarshalling JSON: %v", err)
+		}
+
+		// Check the status code
+		assert.Equal(t, http.StatusOK, rr.Code, amount)
+		if assert.Equal(t, 1, len(resultEmployees), amount) {
+			assert.Equal(t, "Jack", resultEmployees[0].Name)
+		}
+	}
+}
+
+func TestEmployeesBySalaryHandler_PASS_No_Match(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request for a salary one cent off an existing one
+	req := httptest.NewRequest("GET", "/employees/bySalary/{amount}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("amount", "2000.01")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesBySalaryHandler(rr, req)
+
+	// Check the status code and the empty list
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestEmployeesBySalaryHandler_FAIL_Non_Admin_Redacted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+
+	for token, status := range map[string]int{"": http.StatusForbidden, "secret": http.StatusOK} {
+		// Create a request for an existing salary
+		req := httptest.NewRequest("GET", "/employees/bySalary/{amount}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("amount", "60000")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.getEmployeesBySalaryHandler(rr, req)
+
+		// Check only admins can probe salaries while they are hidden
+		assert.Equal(t, status, rr.Code, token)
+	}
+}
+
+func TestEmployeesBySalaryHandler_FAIL_Invalid_Amount(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for amount, message := range map[string]string{
+		"abc":      "Amount must be a number",
+		"NaN":      "Amount must be a number",
+		"2000.001": "Amount cannot have more than two decimals",
+	} {
+		// Create a request with an amount that cannot be a salary
+		req := httptest.NewRequest("GET", "/employees/bySalary/{amount}", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("amount", amount)
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		handler.getEmployeesBySalaryHandler(rr, req)
+
+		// Check the status code
+		assert.Equal(t, http.StatusBadRequest, rr.Code, amount)
+		assert.Contains(t, rr.Body.String(), message, amount)
+	}
+}
+
+// EMPLOYEES ABOVE AVERAGE
+func TestEmployeesAboveAverageHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list the employees above the 40749.75 average
+	req := httptest.NewRequest("GET", "/employees/aboveAverage", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesAboveAverageHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the subset
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 2, resultEmployees[0].ID)
+	assert.Equal(t, 44, resultEmployees[1].ID)
+}
+
+func TestEmployeesAboveAverageHandler_PASS_Paginated(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request for the second page of one
+	req := httptest.NewRequest("GET", "/employees/aboveAverage?page=2&size=1", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesAboveAverageHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the page
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, len(resultEmployees))
+	assert.Equal(t, 44, resultEmployees[0].ID)
+}
+
+// UNMANAGED EMPLOYEES
+func TestUnmanagedEmployeesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	// Alice manages Jack and Mary
+	assignManager(db, 2, []int{3, 4})
+
+	// Create a request to list the employees without a manager
+	req := httptest.NewRequest("GET", "/employees/unmanaged", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getUnmanagedEmployeesHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the top-level employees are listed
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 2, resultEmployees[0].ID)
+	assert.Equal(t, 44, resultEmployees[1].ID)
+}
+
+func TestUnmanagedEmployeesHandler_PASS_Paginated(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	assignManager(db, 2, []int{3})
+
+	// Create a request for the second page of two
+	req := httptest.NewRequest("GET", "/employees/unmanaged?page=2&size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getUnmanagedEmployeesHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the page
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, len(resultEmployees))
+	assert.Equal(t, 44, resultEmployees[0].ID)
+}
+
+func TestRankedEmployeesHandler_PASS_Ties(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Mary earns as much as Jack, and Eve less than both
+	db.Exec("UPDATE employees SET salary_cents = 200000 WHERE id = 4")
+	createEmployee(db, Employee{ID: 5, Name: "Eve", Position: "Intern", Salary: 1000_00})
+
+	// Create a request for the ranking
+	req := httptest.NewRequest("GET", "/employees/ranked", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getRankedEmployeesHandler(rr, req)
+
+	var ranked []RankedEmployee
+	if err := json.Unmarshal(rr.Body.Bytes(), &ranked); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check equal salaries share a rank and the next one follows without a gap
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var ids, ranks []int
+	for _, employee := range ranked {
+		ids = append(ids, employee.ID)
+		ranks = append(ranks, employee.Rank)
+	}
+	assert.Equal(t, []int{44, 2, 3, 4, 5}, ids)
+	assert.Equal(t, []int{1, 2, 3, 3, 4}, ranks)
+}
+
+func TestRankedEmployeesHandler_PASS_Paginated(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request for the second page of two
+	req := httptest.NewRequest("GET", "/employees/ranked?page=2&size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getRankedEmployeesHandler(rr, req)
+
+	var ranked []RankedEmployee
+	if err := json.Unmarshal(rr.Body.Bytes(), &ranked); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check ranks carry on from the first page
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "4", rr.Header().Get("X-Total-Count"))
+	assert.Equal(t, 2, len(ranked))
+	assert.Equal(t, 3, ranked[0].ID)
+	assert.Equal(t, 3, ranked[0].Rank)
+	assert.Equal(t, 4, ranked[1].Rank)
+}
+
+func TestRecentEmployeesHandler_PASS_Ordered_By_Recency(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Mary was created last, but Alice was updated after that
+	db.Exec("UPDATE employees SET created_at = '2024-01-01T00:00:00.000Z', updated_at = '2024-05-01T00:00:00.000Z' WHERE id = 2")
+	db.Exec("UPDATE employees SET created_at = '2024-02-01T00:00:00.000Z' WHERE id = 3")
+	db.Exec("UPDATE employees SET created_at = '2024-03-01T00:00:00.000Z' WHERE id = 4")
+
+	// Create a request for the two most recent employees
+	req := httptest.NewRequest("GET", "/employees/recent?limit=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getRecentEmployeesHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the order
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 2, resultEmployees[0].ID)
+	assert.Equal(t, 4, resultEmployees[1].ID)
+}
+
+func TestRecentEmployeesHandler_PASS_Limit_Capped(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	for id := 100; id < 100+maxRecentLimit; id++ {
+		createEmployee(db, Employee{ID: id, Name: "Bob", Position: "Engineer", Salary: 50000_00})
+	}
+
+	// Create a request asking for more than the cap
+	req := httptest.NewRequest("GET", "/employees/recent?limit=1000", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getRecentEmployeesHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &resultEmployees); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the capped number of employees is returned
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, maxRecentLimit, len(resultEmployees))
+	assert.Equal(t, "true", rr.Header().Get("X-Results-Clamped"))
+}
+
+func TestRecentEmployeesHandler_FAIL_Invalid_Limit(t *testing.T) {
+	handler := Handler{}
+
+	for _, limit := range []string{"0", "-3", "five"} {
+		req := httptest.NewRequest("GET", "/employees/recent?limit="+limit, nil)
+		rr := httptest.NewRecorder()
+		handler.getRecentEmployeesHandler(rr, req)
+
+		// Check the status code
+		assert.Equal(t, http.StatusBadRequest, rr.Code, limit)
+	}
+}
+
+func TestCreateEmployeeHandler_FAIL_Invalid_HireDate(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request with a badly formatted hire date
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00, HireDate: "03/15/2021"}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the status code and that the accepted formats are listed
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "HireDate must be formatted as YYYY-MM-DD or RFC 3339")
+}
+
+func TestCreateEmployeeHandler_PASS_RFC3339_HireDate(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request with the hire date as a full time
+	reqBody := []byte(`{"id":1,"name":"John Doe","position":"Engineer","salary":50000,"hireDate":"2021-03-15T09:30:00+01:00"}`)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the date was stored as YYYY-MM-DD
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	employee, _ := getEmployeeById(db, 1)
+	assert.Equal(t, Date("2021-03-15"), employee.HireDate)
+}
+
+func TestCreateEmployeeHandler_FAIL_Position_Not_Allowed(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{PositionsAllowlist: []string{"Engineer", "Manager"}}}
+	defer handler.db.Close()
+
+	// Create a new request with a position outside the allowlist
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Code Ninja", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "Employee Position must be one of Engineer, Manager\n", rr.Body.String())
+
+	// A listed position is accepted
+	employee.Position = "Engineer"
+	reqBody, _ = json.Marshal(employee)
+	rr = httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody)))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestGetEnumsHandler_PASS(t *testing.T) {
+	handler := Handler{cfg: Config{PositionsAllowlist: []string{"Engineer", "Manager"}}}
+
+	// Create a request for the enums
+	req := httptest.NewRequest("GET", "/enums", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEnumsHandler(rr, req)
+
+	// Check the configured allowlist is returned
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"position":["Engineer","Manager"]}`, rr.Body.String())
+}
+
+func TestGetEnumsHandler_PASS_Unrestricted(t *testing.T) {
+	handler := Handler{}
+
+	// Create a request for the enums without any allowlist
+	req := httptest.NewRequest("GET", "/enums", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEnumsHandler(rr, req)
+
+	// Check unrestricted fields are null
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"position":null}`, rr.Body.String())
+}
+
+func TestCreateEmployeeHandler_PASS_Any_Position_Without_Allowlist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a new request with an unusual position
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Code Ninja", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.createEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+// CLONE EMPLOYEE
+func TestCloneEmployeeHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Sales' WHERE id = 2")
+
+	// Create a request to clone the employee
+	req := httptest.NewRequest("POST", "/employees/{id}/clone", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.cloneEmployeeHandler(rr, req)
+
+	var clone Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &clone); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the copied fields
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.NotEqual(t, 2, clone.ID)
+	assert.Equal(t, "Alice (copy)", clone.Name)
+	assert.Equal(t, "Manager", clone.Position)
+	assert.Equal(t, Cents(60000_00), clone.Salary)
+	assert.Equal(t, "Sales", clone.Department)
+
+	// Check the clone is stored
+	stored, err := getEmployeeById(db, clone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, clone, stored)
+}
+
+func TestCloneEmployeeHandler_FAIL_Does_Not_Exist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to clone a missing employee
+	req := httptest.NewRequest("POST", "/employees/{id}/clone", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "22")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.cloneEmployeeHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Employee does not exist.")
+}
+
+// ASSIGN MANAGER
+func TestSwapPositionsHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request swapping the positions of Alice and Jack
+	reqBody := []byte(`{"a":2,"b":3}`)
+	req := httptest.NewRequest("POST", "/employees/swapPositions", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.swapPositionsHandler(rr, req)
+
+	// Check the status code and the stored positions
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var swapped []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &swapped); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(swapped))
+	alice, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Writer", alice.Position)
+	assert.Equal(t, 2, alice.Version)
+	jack, _ := getEmployeeById(db, 3)
+	assert.Equal(t, "Manager", jack.Position)
+	mary, _ := getEmployeeById(db, 4)
+	assert.Equal(t, "Assistant", mary.Position)
+}
+
+func TestSwapPositionsHandler_FAIL_Missing_Employee(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with an employee that does not exist
+	reqBody := []byte(`{"a":2,"b":22}`)
+	req := httptest.NewRequest("POST", "/employees/swapPositions", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.swapPositionsHandler(rr, req)
+
+	// Check the status code and that nobody was changed
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "employee 22")
+	alice, _ := getEmployeeById(db, 2)
+	assert.Equal(t, "Manager", alice.Position)
+	assert.Equal(t, 1, alice.Version)
+}
+
+func TestAssignManagerHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request assigning Alice as manager of Jack and Mary
+	reqBody := []byte(`{"managerId":2,"employeeIds":[3,4]}`)
+	req := httptest.NewRequest("POST", "/employees/assignManager", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.assignManagerHandler(rr, req)
+
+	// Check the status code and the stored managers, recorded as a change
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"updated":2`)
+	for _, id := range []int{3, 4} {
+		employee, _ := getEmployeeById(db, id)
+		assert.Equal(t, 2, *employee.ManagerID)
+		assert.Equal(t, 2, employee.Version)
+		assert.False(t, employee.UpdatedAt.IsZero())
+	}
+	employee, _ := getEmployeeById(db, 2)
+	assert.Nil(t, employee.ManagerID)
+	assert.Equal(t, 1, employee.Version)
+}
+
+func TestAssignManagerHandler_FAIL_Self_Assignment(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request listing the manager among the employees
+	reqBody := []byte(`{"managerId":2,"employeeIds":[3,2]}`)
+	req := httptest.NewRequest("POST", "/employees/assignManager", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.assignManagerHandler(rr, req)
+
+	// Check the status code and that nobody was changed
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "cannot be their own manager")
+	employee, _ := getEmployeeById(db, 3)
+	assert.Nil(t, employee.ManagerID)
+}
+
+func TestAssignManagerHandler_FAIL_Missing_Employee_Rolls_Back(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request listing an employee that does not exist
+	reqBody := []byte(`{"managerId":2,"employeeIds":[3,22]}`)
+	req := httptest.NewRequest("POST", "/employees/assignManager", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.assignManagerHandler(rr, req)
+
+	// Check the status code and that nobody was changed
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "employee 22")
+	employee, _ := getEmployeeById(db, 3)
+	assert.Nil(t, employee.ManagerID)
+}
+
+// RENAME DEPARTMENT
+func TestRenameDepartmentHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Eng' WHERE id IN (2, 3)")
+	db.Exec("UPDATE employees SET department = 'Engineering Support' WHERE id = 4")
+
+	// Create a request to rename the department
+	reqBody := []byte(`{"from":"Eng","to":"Engineering"}`)
+	req := httptest.NewRequest("POST", "/departments/rename", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.renameDepartmentHandler(rr, req)
+
+	// Check the status code and the affected count
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"updated":2`)
+
+	// Check only the matching rows changed
+	for id, department := range map[int]string{2: "Engineering", 3: "Engineering", 4: "Engineering Support", 44: ""} {
+		employee, _ := getEmployeeById(db, id)
+		assert.Equal(t, department, employee.Department)
+	}
+}
+
+func TestGetDepartmentSalariesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Sales' WHERE id IN (2, 3)")
+	db.Exec("UPDATE employees SET department = 'Support' WHERE id IN (4, 44)")
+
+	// Create a request to aggregate the salaries
+	req := httptest.NewRequest("GET", "/departments/salaries", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getDepartmentSalariesHandler(rr, req)
+
+	var departments []DepartmentSalaries
+	if err := json.Unmarshal(rr.Body.Bytes(), &departments); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the figures, highest total first
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []DepartmentSalaries{
+		{Department: "Support", Total: 100999_00, Average: 50499_50, Count: 2},
+		{Department: "Sales", Total: 62000_00, Average: 31000_00, Count: 2},
+	}, departments)
+}
+
+func TestGetDepartmentSalariesHandler_PASS_Non_Admin_Redacted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET department = 'Sales' WHERE id = 2")
+
+	// Create a request without a token
+	req := httptest.NewRequest("GET", "/departments/salaries", nil)
+	rr := httptest.NewRecorder()
+	handler.getDepartmentSalariesHandler(rr, req)
+
+	var departments []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &departments); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the counts are left, the one-person department gives nothing away
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]interface{}{"department": "Sales", "count": 1.0}, departments[1])
+	for _, department := range departments {
+		assert.NotContains(t, department, "total")
+		assert.NotContains(t, department, "average")
+	}
+}
+
+func TestGetSalaryBandsHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to count the default bands
+	req := httptest.NewRequest("GET", "/employees/bands", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getSalaryBandsHandler(rr, req)
+
+	// Check the status code and the counts, a salary on a bound falls in the band above it
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[
+		{"min": 0, "max": 30000, "count": 2},
+		{"min": 30000, "max": 60000, "count": 0},
+		{"min": 60000, "max": null, "count": 2}
+	]`, rr.Body.String())
+}
+
+func TestGetSalaryBandsHandler_PASS_Configured_Bands(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{SalaryBands: []Cents{1500_00, 50000_00, 70000_00}}}
+	defer handler.db.Close()
+
+	// Create a request to count the configured bands
+	req := httptest.NewRequest("GET", "/employees/bands", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getSalaryBandsHandler(rr, req)
+
+	var bands []SalaryBand
+	if err := json.Unmarshal(rr.Body.Bytes(), &bands); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and the counts
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var counts []int
+	for _, band := range bands {
+		counts = append(counts, band.Count)
+	}
+	assert.Equal(t, []int{1, 1, 1, 1}, counts)
+	assert.Nil(t, bands[3].Max)
+}
+
+func TestRenameDepartmentHandler_FAIL_Blank(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request without a new name
+	reqBody := []byte(`{"from":"Eng"}`)
+	req := httptest.NewRequest("POST", "/departments/rename", bytes.NewReader(reqBody))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.renameDepartmentHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// TAGS
+func TestAddEmployeeTagHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to tag the employee
+	req := httptest.NewRequest("POST", "/employees/{id}/tags/{tag}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+	rctx.URLParams.Add("tag", "remote")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.addEmployeeTagHandler(rr, req)
+
+	// Check the status code and the stored tags
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	tags, err := getEmployeeTags(db, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"remote"}, tags)
+}
+
+func TestAddEmployeeTagHandler_FAIL_Employee_Doesnt_Exist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to tag a missing employee
+	req := httptest.NewRequest("POST", "/employees/{id}/tags/{tag}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "22")
+	rctx.URLParams.Add("tag", "remote")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.addEmployeeTagHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestEmployeeTags_FAIL_Referential_Integrity(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+
+	// Inserting a join row for a missing employee must be rejected
+	_, err := db.Exec("INSERT INTO tags (name) VALUES ('remote')")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO employee_tags (employee_id, tag_id) VALUES (22, 1)")
+	assert.NotNil(t, err)
+
+	// Deleting an employee removes its tags
+	assert.Nil(t, addEmployeeTag(db, 3, "remote"))
+	_, err = deleteEmployee(db, 3, false)
+	assert.Nil(t, err)
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM employee_tags WHERE employee_id = 3").Scan(&count)
+	assert.Equal(t, 0, count)
+}
+
+func TestRemoveEmployeeTagHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	addEmployeeTag(db, 2, "remote")
+	addEmployeeTag(db, 2, "mentor")
+
+	// Create a request to remove the tag
+	req := httptest.NewRequest("DELETE", "/employees/{id}/tags/{tag}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+	rctx.URLParams.Add("tag", "remote")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.removeEmployeeTagHandler(rr, req)
+
+	// Check the status code and the remaining tags
+	assert.Equal(t, http.StatusOK, rr.Code)
+	tags, _ := getEmployeeTags(db, 2)
+	assert.Equal(t, []string{"mentor"}, tags)
+}
+
+func TestRemoveEmployeeTagHandler_FAIL_Not_Tagged(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to remove a tag the employee does not have
+	req := httptest.NewRequest("DELETE", "/employees/{id}/tags/{tag}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+	rctx.URLParams.Add("tag", "remote")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.removeEmployeeTagHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestListEmployeeHandler_PASS_filter_by_tag(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	addEmployeeTag(db, 2, "remote")
+	addEmployeeTag(db, 4, "remote")
+	addEmployeeTag(db, 3, "onsite")
+
+	// Create a request to list the employees with a tag
+	req := httptest.NewRequest("GET", "/getEmployees?tag=remote", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Retrieve the response body
+	responseBody := rr.Body.Bytes()
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(responseBody, &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 2, resultEmployees[0].ID)
+	assert.Equal(t, 4, resultEmployees[1].ID)
+}
+
+func TestListEmployeeHandler_PASS_filter_by_id_range(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list an ID range, sorted and paginated
+	req := httptest.NewRequest("GET", "/getEmployees?idFrom=3&idTo=44&sortBy=id&order=desc&size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the status code and that only the range is returned
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3", rr.Header().Get("X-Total-Count"))
+	assert.Equal(t, 2, len(resultEmployees))
+	assert.Equal(t, 44, resultEmployees[0].ID)
+	assert.Equal(t, 4, resultEmployees[1].ID)
+}
+
+func TestListEmployeeHandler_PASS_filter_by_position(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET position = 'Writer' WHERE id = 44")
+	db.Exec("UPDATE employees SET position = 'Writer in chief' WHERE id = 2")
+
+	// Create a request to list one page of the writers
+	req := httptest.NewRequest("GET", "/getEmployees?position=Writer&size=1", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var resultEmployees []Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &resultEmployees}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only exact matches are counted and returned
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-Total-Count"))
+	assert.Equal(t, 1, len(resultEmployees))
+	assert.Equal(t, 3, resultEmployees[0].ID)
+
+	// An empty position lists everyone
+	req = httptest.NewRequest("GET", "/getEmployees?position=", nil)
+	rr = httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+	assert.Equal(t, "4", rr.Header().Get("X-Total-Count"))
+}
+
+func TestListEmployeeHandler_FAIL_id_range_reversed(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request with idFrom greater than idTo
+	req := httptest.NewRequest("GET", "/getEmployees?idFrom=20&idTo=10", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "idFrom cannot be greater than idTo\n", rr.Body.String())
+}
+
+func TestGetEmployeeNamesHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list a page of names
+	req := httptest.NewRequest("GET", "/employees/names?sortBy=name&size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeNamesHandler(rr, req)
+
+	var result []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check only the ID and name are returned
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": 2.0, "name": "Alice"},
+		{"id": 44.0, "name": "Duplicate"},
+	}, result)
+}
+
+func TestGetEmployeeNamesHandler_PASS_Search(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+	db.Exec("INSERT INTO employees (id, name, position, salary_cents) VALUES (5, '100% Jane', 'Tester', 100000)")
+
+	// Search ignores case and matches wildcards literally
+	for search, expected := range map[string][]EmployeeName{
+		"A":  {{ID: 2, Name: "Alice"}, {ID: 3, Name: "Jack"}, {ID: 4, Name: "Mary"}, {ID: 5, Name: "100% Jane"}, {ID: 44, Name: "Duplicate"}},
+		"%":  {{ID: 5, Name: "100% Jane"}},
+		"zz": {},
+	} {
+		req := httptest.NewRequest("GET", "/employees/names?search="+url.QueryEscape(search), nil)
+		rr := httptest.NewRecorder()
+		handler.getEmployeeNamesHandler(rr, req)
+
+		var result []EmployeeName
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, expected, result, search)
+	}
+}
+
+func TestListEmployeeHandler_PASS_as_map(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to list the employees keyed by ID
+	req := httptest.NewRequest("GET", "/getEmployees?as=map&size=3", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result map[string]Employee
+	if err := json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &result}); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check each key is the ID of its employee
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 3, len(result))
+	for key, employee := range result {
+		assert.Equal(t, key, strconv.Itoa(employee.ID))
+	}
+	assert.Equal(t, "Alice", result["2"].Name)
+}
+
+func TestListEmployeeHandler_FAIL_as_unknown(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	req := httptest.NewRequest("GET", "/getEmployees?as=csv", nil)
+	rr := httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "as must be array or map\n", rr.Body.String())
+}
+
+// SALARY REDACTION
+func TestGetEmployeeHandler_PASS_Admin_Sees_Salary(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+
+	// Create a request as admin
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	// Check the salary is present
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 60000.0, result["salary"])
+}
+
+func TestGetEmployeeHandler_PASS_Non_Admin_Salary_Redacted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+
+	// Create a request with a wrong token
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	req.Header.Set("Authorization", "Bearer guess")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	// Check the salary is omitted but the rest is present
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, result, "salary")
+	assert.Equal(t, "Alice", result["name"])
+}
+
+func TestListEmployeeHandler_PASS_Non_Admin_Salary_Redacted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+
+	// Create a request without a token
+	req := httptest.NewRequest("GET", "/getEmployees", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &listBody{Data: &result})
+
+	// Check no employee has a salary
+	assert.Equal(t, 4, len(result))
+	for _, employee := range result {
+		assert.NotContains(t, employee, "salary")
+	}
+}
+
+func TestListEmployeeHandler_PASS_Non_Admin_Cost_Redacted(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{AdminToken: "secret", RedactSalary: true}}
+	defer handler.db.Close()
+
+	// Ask for the cost of a single employee without a token
+	req := httptest.NewRequest("GET", "/getEmployees?idFrom=2&idTo=2&includeCost=true", nil)
+	rr := httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+
+	// Check the cost is left out rather than revealing the salary
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "totalSalary")
+	assert.NotContains(t, rr.Body.String(), "60000")
+	assert.Equal(t, "", rr.Header().Get("X-Total-Salary"))
+
+	// An admin still gets it
+	req = httptest.NewRequest("GET", "/getEmployees?idFrom=2&idTo=2&includeCost=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+	assert.Equal(t, "60000.00", rr.Header().Get("X-Total-Salary"))
+}
+
+// RESPONSE ENVELOPE
+func TestGetEmployeeHandler_PASS_Bare(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to get an employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	// Check the employee is the body itself
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Alice", result["name"])
+	assert.NotContains(t, result, "data")
+}
+
+func TestGetEmployeeHandler_PASS_Enveloped(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	// Create a request to get an employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result struct {
+		Data Employee               `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the employee is wrapped with empty metadata
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Alice", result.Data.Name)
+	assert.Equal(t, map[string]interface{}{}, result.Meta)
+}
+
+func TestListEmployeeHandler_PASS_Enveloped(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	// Create a request to list a page of employees
+	req := httptest.NewRequest("GET", "/getEmployees?size=2", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result struct {
+		Data []Employee             `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the page is wrapped with the pagination metadata
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, len(result.Data))
+	assert.Equal(t, map[string]interface{}{"total": 4.0, "size": 2.0, "offset": 0.0, "outOfRange": false,
+		"clamped": false, "page": 1.0, "totalPages": 2.0},
+		result.Meta)
+}
+
+func TestListEmployeeHandler_PASS_Include_Cost(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	// Ask for one employee of the filtered set, with the salary cost
+	req := httptest.NewRequest("GET", "/getEmployees?size=1&minSalary=50000&includeCost=true", nil)
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result struct {
+		Data []Employee             `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the cost covers Duplicate and Alice, not only the page
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, len(result.Data))
+	assert.Equal(t, 159999.0, result.Meta["totalSalary"])
+	assert.Equal(t, "159999.00", rr.Header().Get("X-Total-Salary"))
+
+	// Without includeCost the meta has no cost
+	req = httptest.NewRequest("GET", "/getEmployees?size=1", nil)
+	rr = httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+	assert.NotContains(t, rr.Body.String(), "totalSalary")
+	assert.Equal(t, "", rr.Header().Get("X-Total-Salary"))
+}
+
+func TestListEmployeeHandler_PASS_Page_Count(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	for _, c := range []struct {
+		query string
+		want  map[string]interface{}
+	}{
+		{"page=2&size=3", map[string]interface{}{"page": 2.0, "size": 3.0, "offset": 3.0, "total": 4.0, "totalPages": 2.0,
+			"outOfRange": false, "clamped": false}},
+		{"page=1&size=4", map[string]interface{}{"page": 1.0, "size": 4.0, "offset": 0.0, "total": 4.0, "totalPages": 1.0,
+			"outOfRange": false, "clamped": false}},
+		// An offset off a page boundary has no page number
+		{"offset=2&limit=3", map[string]interface{}{"size": 3.0, "offset": 2.0, "total": 4.0, "totalPages": 2.0,
+			"outOfRange": false, "clamped": false}},
+	} {
+		req := httptest.NewRequest("GET", "/getEmployees?"+c.query, nil)
+		rr := httptest.NewRecorder()
+		handler.getEmployeesListHandler(rr, req)
+
+		var result map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &result)
+
+		// Check the pagination sits next to the data, with the page count rounded up
+		assert.Equal(t, http.StatusOK, rr.Code, c.query)
+		assert.NotNil(t, result["data"], c.query)
+		delete(result, "data")
+		assert.Equal(t, c.want, result, c.query)
+	}
+
+	// An empty table has no pages and an empty list
+	db.Exec("DELETE FROM employees")
+	req := httptest.NewRequest("GET", "/getEmployees", nil)
+	rr := httptest.NewRecorder()
+	handler.getEmployeesListHandler(rr, req)
+	assert.Equal(t, `{"clamped":false,"data":[],"offset":0,"outOfRange":false,"page":1,"size":10,"total":0,"totalPages":0}`+"\n",
+		rr.Body.String())
+}
+
+func TestListEmployeeHandler_PASS_Enveloped_Page_Count(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	for query, page := range map[string]interface{}{"page=2&size=3": 2.0, "offset=2&limit=1": nil} {
+		req := httptest.NewRequest("GET", "/getEmployees?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.getEmployeesListHandler(rr, req)
+
+		var result struct {
+			Meta map[string]interface{} `json:"meta"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &result)
+
+		// Check the meta page comes from the page parameter only
+		assert.Equal(t, page, result.Meta["page"], query)
+	}
+}
+
+func TestListEndpoints_PASS_Same_Pagination_Metadata(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	byYear := httptest.NewRequest("GET", "/employees/byYear/{year}?size=1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("year", "2021")
+	byYear = byYear.WithContext(context.WithValue(byYear.Context(), chi.RouteCtxKey, rctx))
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+		total   string
+	}{
+		{"list", handler.getEmployeesListHandler, httptest.NewRequest("GET", "/getEmployees?size=1", nil), "4"},
+		{"search", handler.getEmployeesListHandler, httptest.NewRequest("GET", "/getEmployees?size=1&search=ar", nil), "1"},
+		{"filtered", handler.getEmployeesListHandler, httptest.NewRequest("GET", "/getEmployees?size=1&idFrom=3", nil), "3"},
+		{"names", handler.getEmployeeNamesHandler, httptest.NewRequest("GET", "/employees/names?size=1", nil), "4"},
+		{"byYear", handler.getEmployeesByYearHandler, byYear, "2"},
+		{"aboveAverage", handler.getEmployeesAboveAverageHandler, httptest.NewRequest("GET", "/employees/aboveAverage?size=1", nil), "2"},
+		{"unmanaged", handler.getUnmanagedEmployeesHandler, httptest.NewRequest("GET", "/employees/unmanaged?size=1", nil), "4"},
+		{"ranked", handler.getRankedEmployeesHandler, httptest.NewRequest("GET", "/employees/ranked?size=1", nil), "4"},
+	}
+	for _, c := range cases {
+		// Create a response recorder to record the response
+		rr := httptest.NewRecorder()
+
+		// Call the handler function
+		c.handler(rr, c.req)
+
+		var result struct {
+			Meta map[string]interface{} `json:"meta"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Errorf("%s: error unmarshalling JSON: %v", c.name, err)
+		}
+
+		// Check every list carries the same metadata
+		assert.Equal(t, http.StatusOK, rr.Code, c.name)
+		assert.Equal(t, c.total, rr.Header().Get("X-Total-Count"), c.name)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"), c.name)
+		total, _ := strconv.Atoi(c.total)
+		assert.Equal(t, map[string]interface{}{"total": float64(total), "size": 1.0, "offset": 0.0, "outOfRange": false,
+			"clamped": false, "page": 1.0, "totalPages": float64(total)},
+			result.Meta, c.name)
+	}
+
+	// Without the envelope the list sends the same keys next to its data
+	handler.cfg.Envelope = false
+	for query, total := range map[string]float64{"size=1": 4, "size=1&search=ar": 1, "size=1&idFrom=3": 3} {
+		rr := httptest.NewRecorder()
+		handler.getEmployeesListHandler(rr, httptest.NewRequest("GET", "/getEmployees?"+query, nil))
+
+		var result map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Errorf("%s: error unmarshalling JSON: %v", query, err)
+		}
+		assert.Contains(t, result, "data", query)
+		delete(result, "data")
+		assert.Equal(t, map[string]interface{}{"total": total, "size": 1.0, "offset": 0.0, "outOfRange": false,
+			"clamped": false, "page": 1.0, "totalPages": total},
+			result, query)
+	}
+}
+
+func TestListEmployeeHandler_PASS_Page_Out_Of_Range(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true}}
+	defer handler.db.Close()
+
+	// Create a request for a page far past the four employees
+	req := httptest.NewRequest("GET", "/getEmployees?page=100&size=10", nil)
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result struct {
+		Data []Employee             `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the empty page is flagged as out of range
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, result.Data)
+	assert.Equal(t, map[string]interface{}{"total": 4.0, "size": 10.0, "offset": 990.0, "outOfRange": true,
+		"clamped": false, "page": 100.0, "totalPages": 1.0},
+		result.Meta)
+	assert.Equal(t, "true", rr.Header().Get("X-Page-Out-Of-Range"))
+}
+
+func TestListEmployeeHandler_PASS_Clamped_To_Absolute_Max(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Envelope: true, AbsoluteMaxResults: 3}}
+	defer handler.db.Close()
+
+	// Ask for more employees than any page may hold
+	req := httptest.NewRequest("GET", "/getEmployees?size=500", nil)
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeesListHandler(rr, req)
+
+	var result struct {
+		Data []Employee             `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the page was cut down and flagged
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 3, len(result.Data))
+	assert.Equal(t, map[string]interface{}{"total": 4.0, "size": 3.0, "offset": 0.0, "outOfRange": false,
+		"clamped": true, "page": 1.0, "totalPages": 2.0},
+		result.Meta)
+	assert.Equal(t, "true", rr.Header().Get("X-Results-Clamped"))
+}
+
+// INTERNAL ERRORS
+func TestGetEmployeeHandler_FAIL_Internal_Error_Hides_Details(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	// Closing the database makes every query fail
+	db.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// Create a request to get an employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	// Check the client only gets a reference
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Regexp(t, `^internal error, ref: [0-9a-f]{12}\n$`, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "database is closed")
+
+	// Check the details are logged under the same reference
+	ref := strings.TrimSpace(strings.TrimPrefix(rr.Body.String(), "internal error, ref: "))
+	assert.Contains(t, logs.String(), "ref "+ref)
+	assert.Contains(t, logs.String(), "database is closed")
+}
+
+// SET UP
+// FULL HTTP STACK
+func TestRouter_PASS_Create_Get_Delete(t *testing.T) {
+	db := setupDatabase()
+	defer db.Close()
+	server := httptest.NewServer(newRouter(newHandler(Config{}, db)))
+	defer server.Close()
+
+	// Create an employee
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	resp, err := http.Post(server.URL+"/createEmployee", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/employees/1", resp.Header.Get("Location"))
+
+	// Get it back from its location
+	resp, err = http.Get(server.URL + resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Employee
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "John Doe", stored.Name)
+
+	// Delete it
+	req, _ := http.NewRequest("DELETE", server.URL+"/deleteEmployee/1", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// Check it is gone
+	resp, err = http.Get(server.URL + "/employees/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func setupDatabase() *sql.DB {
+	return setupDatabaseWithDriver("sqlite3")
+}
+
+func setupDatabaseWithDriver(driverName string) *sql.DB {
+	db, _ := sql.Open(driverName, ":memory:?_foreign_keys=on")
+	// Every connection to :memory: is a new database, so keep a single one
+	db.SetMaxOpenConns(1)
+	_ = initSchema(db)
+	db.Exec("INSERT INTO employees (id, name, position, salary_cents) VALUES (?, ?, ?, ?)",
+		"44", "Duplicate", "Redundant", "9999900")
+	db.Exec("INSERT INTO employees (id, name, position, salary_cents, hire_date) VALUES (?, ?, ?, ?, ?)",
+		"2", "Alice", "Manager", "6000000", "2021-03-15")
+	db.Exec("INSERT INTO employees (id, name, position, salary_cents, hire_date) VALUES (?, ?, ?, ?, ?)",
+		"3", "Jack", "Writer", "200000", "2022-07-01")
+	db.Exec("INSERT INTO employees (id, name, position, salary_cents, hire_date) VALUES (?, ?, ?, ?, ?)",
+		"4", "Mary", "Assistant", "100000", "2021-11-30")
 	return db
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Employees the database is reseeded with on reset
+var seedEmployees = []Employee{
+	{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00, Department: "Engineering", HireDate: "2020-01-06"},
+	{ID: 2, Name: "Alice", Position: "Manager", Salary: 60000_00, Department: "Engineering", HireDate: "2021-03-15"},
+	{ID: 3, Name: "Jack", Position: "Writer", Salary: 2000_00, Department: "Marketing", HireDate: "2022-07-01"},
+}
+
+// Answer 404 unless the server runs in test mode, so test-only endpoints do
+// not exist in production
+func (h *Handler) requireTestMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.cfg.TestMode {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) resetDatabaseHandler(w http.ResponseWriter, r *http.Request) {
+	// call DB layer
+	if err := resetDatabase(h.db, seedEmployees); err != nil {
+		internalError(w, "Error while resetting the database", err)
+		return
+	}
+	h.cache.clear()
+
+	// Send Response
+	w.WriteHeader(http.StatusNoContent)
+}
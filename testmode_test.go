@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetDatabaseHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{TestMode: true}}
+	defer handler.db.Close()
+	addEmployeeTag(db, 2, "mentor")
+
+	// Create a request to reset the database
+	req := httptest.NewRequest("POST", "/test/reset", nil)
+	rr := httptest.NewRecorder()
+	handler.requireTestMode(http.HandlerFunc(handler.resetDatabaseHandler)).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Check the previous employees are gone
+	_, err := getEmployeeById(db, 44)
+	assert.NotNil(t, err)
+	tags, _ := getEmployeeTags(db, 2)
+	assert.Empty(t, tags)
+
+	// Check the seed employees are stored
+	count, err := countEmployees(db, EmployeeFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, len(seedEmployees), count)
+	stored, err := getEmployeeById(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "John Doe", stored.Name)
+}
+
+func TestResetDatabaseHandler_FAIL_Not_Test_Mode(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to reset the database outside test mode
+	req := httptest.NewRequest("POST", "/test/reset", nil)
+	rr := httptest.NewRecorder()
+	handler.requireTestMode(http.HandlerFunc(handler.resetDatabaseHandler)).ServeHTTP(rr, req)
+
+	// Check the endpoint does not exist and nothing changed
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	_, err := getEmployeeById(db, 44)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Layout timestamps are stored in. It is fixed width and always UTC, so
+// timestamps sort correctly as text in SQL.
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// Layout timestamps are written in JSON, RFC 3339 with milliseconds and a Z
+// suffix in UTC
+const timestampJSONLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// SQL expression producing the current time in timestampLayout
+const sqlNow = "strftime('%Y-%m-%dT%H:%M:%fZ', 'now')"
+
+// Timestamp Type:
+// A point in time set by the server, null in JSON when unknown (rows written
+// before timestamps were recorded).
+type Timestamp struct {
+	time.Time
+}
+
+// Write the time as RFC 3339 in its own location, or null when unset. Times
+// read from the database are in UTC until moved to the configured timezone.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.Format(timestampJSONLayout) + `"`), nil
+}
+
+// Read a time in any of inputTimeLayouts, or null
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := parseInputTime(value)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Read the column written with timestampLayout, NULL leaves it unset
+func (t *Timestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into Timestamp", value)
+}
+
+func (t *Timestamp) parse(value string) error {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Layouts accepted for dates and times sent by clients, tried in order. A
+// date alone is midnight UTC.
+var inputTimeLayouts = []string{hireDateLayout, time.RFC3339Nano}
+
+// The accepted layouts as written in error messages
+const inputTimeFormats = "YYYY-MM-DD or RFC 3339 such as 2021-03-15T09:00:00Z"
+
+// Parse a date or time sent by a client
+func parseInputTime(value string) (time.Time, error) {
+	for _, layout := range inputTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q must be formatted as %s", value, inputTimeFormats)
+}
+
+// Date Type:
+// A calendar date, stored and written as YYYY-MM-DD. Clients may send any of
+// inputTimeLayouts, a full time keeps the date it has in its own offset.
+type Date string
+
+// Read the date, normalized to YYYY-MM-DD. A value in no accepted layout is
+// kept as sent so validation can report it.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if parsed, err := parseInputTime(value); err == nil {
+		value = parsed.Format(hireDateLayout)
+	}
+	*d = Date(value)
+	return nil
+}
+
+// Whether the date is a valid YYYY-MM-DD
+func (d Date) valid() bool {
+	_, err := time.Parse(hireDateLayout, string(d))
+	return err == nil
+}
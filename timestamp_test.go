@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampMarshalJSON_PASS_UTC(t *testing.T) {
+	timestamp := Timestamp{time.Date(2024, 1, 2, 3, 4, 5, 600_000_000, time.UTC)}
+
+	data, err := json.Marshal(timestamp)
+
+	// Check the fixed width RFC 3339 format with a Z suffix
+	assert.Nil(t, err)
+	assert.Equal(t, `"2024-01-02T03:04:05.600Z"`, string(data))
+}
+
+func TestTimestampMarshalJSON_PASS_Null(t *testing.T) {
+	data, err := json.Marshal(Timestamp{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "null", string(data))
+}
+
+func TestDateUnmarshalJSON_PASS_Accepted_Formats(t *testing.T) {
+	for input, expected := range map[string]Date{
+		`"2021-03-15"`:                    "2021-03-15",
+		`"2021-03-15T09:30:00Z"`:          "2021-03-15",
+		`"2021-03-15T09:30:00.250Z"`:      "2021-03-15",
+		`"2021-03-15T23:30:00-05:00"`:     "2021-03-15",
+		`"2021-03-15T00:30:00.000+02:00"`: "2021-03-15",
+	} {
+		var date Date
+		err := json.Unmarshal([]byte(input), &date)
+
+		// Check the date is kept in its own offset, normalized to YYYY-MM-DD
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, date, input)
+		assert.True(t, date.valid(), input)
+	}
+}
+
+func TestDateUnmarshalJSON_FAIL_Invalid(t *testing.T) {
+	var date Date
+	err := json.Unmarshal([]byte(`"03/15/2021"`), &date)
+
+	// Check the value is kept as sent for validation to report
+	assert.Nil(t, err)
+	assert.Equal(t, Date("03/15/2021"), date)
+	assert.False(t, date.valid())
+}
+
+func TestGetEmployeeHandler_PASS_Timestamps_In_Timezone(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{Timezone: time.FixedZone("UTC+2", 2*60*60)}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET created_at = '2024-01-02T23:30:00.000Z', updated_at = NULL WHERE id = 2")
+
+	// Create a request to get the employee
+	req := httptest.NewRequest("GET", "/employees/{id}", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeByIdHandler(rr, req)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Errorf("Error unmarshalling JSON: %v", err)
+	}
+
+	// Check the timestamp is written with the configured offset
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2024-01-03T01:30:00.000+02:00", result["createdAt"])
+	assert.Nil(t, result["updatedAt"])
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// Minimum TLS versions accepted in TLS_MIN_VERSION
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Whether the server is configured to serve HTTPS
+func (c Config) tlsEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Build the HTTP server, refusing TLS versions older than the configured minimum
+func newServer(cfg Config, handler http.Handler) *http.Server {
+	minVersion, ok := tlsVersions[cfg.TLSMinVersion]
+	if !ok {
+		minVersion = tls.VersionTLS12
+	}
+	return &http.Server{
+		Handler:   handler,
+		TLSConfig: &tls.Config{MinVersion: minVersion},
+	}
+}
+
+// Serve HTTPS on the listener when a certificate and key are configured, plain HTTP otherwise
+func serve(srv *http.Server, ln net.Listener, cfg Config) error {
+	if cfg.tlsEnabled() {
+		return srv.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+	}
+	return srv.Serve(ln)
+}
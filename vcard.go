@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func (h *Handler) getEmployeeVCardHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse Request
+	id, err := h.parseEmployeeID(r)
+	if err != nil {
+		writeIDParamError(w, err)
+		return
+	}
+
+	// Call DB layer
+	employee, err := h.cache.get(id, func(id int) (Employee, error) {
+		return getEmployeeById(h.dbFor(r), id)
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmployeeNotFound) {
+			http.Error(w, "Employee does not exist.",
+				http.StatusNotFound)
+			return
+		}
+		internalError(w, "Error while getting employee", err)
+		return
+	}
+
+	// Send Response
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="employee-%d.vcf"`, employee.ID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(formatVCard(employee, h.cfg.OrgName)))
+}
+
+// Format the employee as a vCard 3.0
+func formatVCard(emp Employee, orgName string) string {
+	// The structured name is family;given, split on the last space
+	given, family := emp.Name, ""
+	if i := strings.LastIndex(emp.Name, " "); i > 0 {
+		given, family = emp.Name[:i], emp.Name[i+1:]
+	}
+
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:" + escapeVCard(family) + ";" + escapeVCard(given) + ";;;",
+		"FN:" + escapeVCard(emp.Name),
+		"TITLE:" + escapeVCard(emp.Position),
+	}
+	if orgName != "" || emp.Department != "" {
+		lines = append(lines, "ORG:"+escapeVCard(orgName)+";"+escapeVCard(emp.Department))
+	}
+	lines = append(lines, fmt.Sprintf("UID:employee-%d", emp.ID), "END:VCARD")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// Escape the characters that have a meaning in vCard values
+func escapeVCard(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEmployeeVCardHandler_PASS(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db, cfg: Config{OrgName: "Acme, Inc."}}
+	defer handler.db.Close()
+	db.Exec("UPDATE employees SET name = 'Alice Smith', department = 'Sales' WHERE id = 2")
+
+	// Create a request to export the employee
+	req := httptest.NewRequest("GET", "/employees/{id}.vcf", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeVCardHandler(rr, req)
+
+	// Check the headers and the card
+	body := rr.Body.String()
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/vcard; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), `filename="employee-2.vcf"`)
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCARD\r\nVERSION:3.0\r\n"))
+	assert.Contains(t, body, "N:Smith;Alice;;;\r\n")
+	assert.Contains(t, body, "FN:Alice Smith\r\n")
+	assert.Contains(t, body, "TITLE:Manager\r\n")
+	assert.Contains(t, body, "ORG:Acme\\, Inc.;Sales\r\n")
+	assert.True(t, strings.HasSuffix(body, "END:VCARD\r\n"))
+}
+
+func TestGetEmployeeVCardHandler_FAIL_Does_Not_Exist(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to export a missing employee
+	req := httptest.NewRequest("GET", "/employees/{id}.vcf", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "22")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Create a response recorder to record the response
+	rr := httptest.NewRecorder()
+
+	// Call the handler function
+	handler.getEmployeeVCardHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
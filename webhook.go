@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Delivers employee events to an outbound webhook. Events still failing after
+// the retries are kept in the dead_letter table when there is a database.
+type webhookNotifier struct {
+	url     string
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	db      *sql.DB
+}
+
+func newWebhookNotifier(cfg Config, db *sql.DB) *webhookNotifier {
+	return &webhookNotifier{
+		url:     cfg.WebhookURL,
+		retries: cfg.WebhookRetries,
+		backoff: cfg.WebhookBackoff,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		db:      db,
+	}
+}
+
+// Deliver the event in the background so the response is not delayed
+func (n *webhookNotifier) notify(event EmployeeEvent) {
+	go func() {
+		if err := n.deliver(event); err != nil {
+			log.Printf("Webhook delivery of event %d failed: %v", event.Seq, err)
+		}
+	}()
+}
+
+// POST the event, retrying with exponential backoff until it is accepted.
+// An event that is never accepted becomes a dead letter.
+func (n *webhookNotifier) deliver(event EmployeeEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err = n.postWithRetries(body); err != nil && n.db != nil {
+		if dlErr := addDeadLetter(n.db, body, err); dlErr != nil {
+			log.Printf("Error while keeping the failed webhook event %d: %v", event.Seq, dlErr)
+		}
+	}
+	return err
+}
+
+func (n *webhookNotifier) postWithRetries(body []byte) error {
+	delay := n.backoff
+	for attempt := 0; ; attempt++ {
+		err := n.post(body)
+		if err == nil || attempt >= n.retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (n *webhookNotifier) post(body []byte) error {
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// WebhookRetryResult Struct:
+// Outcome of retrying the dead letters.
+type WebhookRetryResult struct {
+	//Dead letters delivered and removed.
+	Delivered int `json:"delivered"`
+	//Dead letters failing again, kept for a later retry.
+	Failed int `json:"failed"`
+}
+
+// Attempt each dead letter once, removing those the webhook accepts
+func (n *webhookNotifier) retryDeadLetters() (WebhookRetryResult, error) {
+	var result WebhookRetryResult
+	letters, err := getDeadLetters(n.db)
+	if err != nil {
+		return result, err
+	}
+	for _, letter := range letters {
+		if err := n.post(letter.Event); err != nil {
+			result.Failed++
+			if err := failDeadLetter(n.db, letter.ID, err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		result.Delivered++
+		if err := deleteDeadLetter(n.db, letter.ID); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
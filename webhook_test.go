@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_PASS_Receives_Event_After_Retry(t *testing.T) {
+	// Webhook receiver failing the first delivery
+	var attempts int32
+	received := make(chan EmployeeEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event EmployeeEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer receiver.Close()
+
+	db := setupDatabase()
+	cfg := Config{WebhookURL: receiver.URL, WebhookRetries: 3, WebhookBackoff: 10 * time.Millisecond}
+	handler := Handler{db: db, webhooks: newWebhookNotifier(cfg, nil)}
+	defer handler.db.Close()
+
+	// Create an employee
+	employee := Employee{ID: 1, Name: "John Doe", Position: "Engineer", Salary: 50000_00}
+	reqBody, _ := json.Marshal(employee)
+	req := httptest.NewRequest("POST", "/createEmployee", bytes.NewReader(reqBody))
+	rr := httptest.NewRecorder()
+	handler.createEmployeeHandler(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Check the webhook receives the event
+	select {
+	case event := <-received:
+		assert.Equal(t, EventEmployeeCreated, event.Type)
+		assert.Equal(t, 1, event.EmployeeID)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Webhook did not receive the event")
+	}
+}
+
+func TestWebhook_FAIL_Gives_Up_After_Retries(t *testing.T) {
+	// Webhook receiver that always fails
+	var attempts int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	cfg := Config{WebhookURL: receiver.URL, WebhookRetries: 2, WebhookBackoff: time.Millisecond}
+	err := newWebhookNotifier(cfg, nil).deliver(EmployeeEvent{Type: EventEmployeeDeleted, EmployeeID: 2})
+
+	// One attempt plus two retries
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryWebhooksHandler_PASS_Dead_Letter_Delivered(t *testing.T) {
+	// Webhook receiver that is down until told otherwise
+	var up int32
+	received := make(chan EmployeeEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event EmployeeEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer receiver.Close()
+
+	db := setupDatabase()
+	cfg := Config{WebhookURL: receiver.URL, WebhookRetries: 1, WebhookBackoff: time.Millisecond}
+	handler := Handler{db: db, webhooks: newWebhookNotifier(cfg, db)}
+	defer handler.db.Close()
+
+	// The delivery fails every retry and becomes a dead letter
+	err := handler.webhooks.deliver(EmployeeEvent{Seq: 7, Type: EventEmployeeDeleted, EmployeeID: 2})
+	assert.NotNil(t, err)
+	letters, _ := getDeadLetters(db)
+	assert.Equal(t, 1, len(letters))
+
+	// A retry while the receiver is still down keeps it
+	req := httptest.NewRequest("POST", "/admin/webhooks/retry", nil)
+	rr := httptest.NewRecorder()
+	handler.retryWebhooksHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"delivered":0,"failed":1}`, rr.Body.String())
+	letters, _ = getDeadLetters(db)
+	assert.Equal(t, 2, letters[0].Attempts)
+
+	// Once the receiver is back the retry delivers it and clears the row
+	atomic.StoreInt32(&up, 1)
+	rr = httptest.NewRecorder()
+	handler.retryWebhooksHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"delivered":1,"failed":0}`, rr.Body.String())
+	event := <-received
+	assert.Equal(t, 7, event.Seq)
+	assert.Equal(t, 2, event.EmployeeID)
+	letters, _ = getDeadLetters(db)
+	assert.Equal(t, 0, len(letters))
+}
+
+func TestRetryWebhooksHandler_FAIL_Not_Configured(t *testing.T) {
+	db := setupDatabase()
+	handler := Handler{db: db}
+	defer handler.db.Close()
+
+	// Create a request to retry without a webhook
+	req := httptest.NewRequest("POST", "/admin/webhooks/retry", nil)
+	rr := httptest.NewRecorder()
+	handler.retryWebhooksHandler(rr, req)
+
+	// Check the status code
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Webhooks are not configured")
+}